@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// adaptiveTimeoutWindow is the number of latest response times kept to compute
+	// the adaptive timeouts.
+	adaptiveTimeoutWindow = 100
+	// adaptiveTimeoutMinSamples is the number of response times needed before the
+	// timeouts adapt; the ceiling is used until then.
+	adaptiveTimeoutMinSamples = 10
+)
+
+// WithAdaptiveTimeout limits the duration of each execution, as
+// WithTimeoutResilience does, to a timeout that follows the latency of the
+// upstream: the given percentile, from 0 to 100, of the latest response times,
+// clamped between floor and ceiling. The ceiling is used until enough responses
+// are observed. Executions that time out return ErrTimeout and count as taking
+// the whole timeout, so the timeouts grow back as the latency increases.
+//
+// The response times are observed per client, so use separate clients for
+// upstreams with different latencies.
+func WithAdaptiveTimeout(percentile float64, floor, ceiling time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		window := &latencyWindow{}
+		client.chainContextCallback(func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+			timeout := ceiling
+			if latency, ok := window.percentile(percentile); ok {
+				timeout = clampDuration(latency, floor, ceiling)
+			}
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			resp, err := fn(timeoutCtx)
+			if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+				window.add(timeout)
+				return resp, ErrTimeout
+			}
+			if resp != nil {
+				window.add(resp.ResponseTime())
+			}
+			return resp, err
+		})
+	}
+}
+
+// latencyWindow keeps the latest response times in a ring buffer.
+type latencyWindow struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (w *latencyWindow) add(d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.samples) < adaptiveTimeoutWindow {
+		w.samples = append(w.samples, d)
+		return
+	}
+	w.samples[w.next] = d
+	w.next = (w.next + 1) % adaptiveTimeoutWindow
+}
+
+// percentile returns the p percentile of the response times, or false when
+// there aren't enough of them.
+func (w *latencyWindow) percentile(p float64) (time.Duration, bool) {
+	w.mu.Lock()
+	samples := append([]time.Duration(nil), w.samples...)
+	w.mu.Unlock()
+
+	if len(samples) < adaptiveTimeoutMinSamples {
+		return 0, false
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	i := int(math.Ceil(p/100*float64(len(samples)))) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(samples) {
+		i = len(samples) - 1
+	}
+	return samples[i], true
+}
+
+func clampDuration(d, floor, ceiling time.Duration) time.Duration {
+	if d < floor {
+		return floor
+	}
+	if d > ceiling {
+		return ceiling
+	}
+	return d
+}
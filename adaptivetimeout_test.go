@@ -0,0 +1,40 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithAdaptiveTimeout(90, 50*time.Millisecond, time.Second),
+	)
+
+	_, err := client.NewRequest().Get("/slow")
+	assert.NoError(t, err, "the ceiling is used until enough responses are observed")
+
+	for i := 0; i < 20; i++ {
+		_, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+	}
+
+	start := time.Now()
+	_, err = client.NewRequest().Get("/slow")
+	assert.Equal(t, httpclient.ErrTimeout, err)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
@@ -0,0 +1,89 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrNilBatchRequest is the error of the nil requests given to ExecuteBatch.
+var ErrNilBatchRequest = errors.New("nil batch request")
+
+// BatchRequest is a request executed by HTTPClient.ExecuteBatch.
+type BatchRequest struct {
+	// Method is the HTTP method, GET when empty.
+	Method string
+	URL    string
+	// Body is set with Request.SetBody when not nil.
+	Body interface{}
+}
+
+// BatchResult is the outcome of a BatchRequest.
+type BatchResult struct {
+	Response *Response
+	Err      error
+}
+
+// ExecuteBatch executes the requests concurrently, running at most concurrency of
+// them at a time, or all of them at once when it's zero or negative, and returns
+// their results in the same order. Each request goes through the resilience,
+// metrics and middlewares of the client, as if executed with NewRequest. Nil
+// requests aren't executed and get ErrNilBatchRequest.
+//
+// The requests are executed with ctx: once it's done, the running requests are
+// cancelled and the ones not started yet fail with its error.
+func (c *HTTPClient) ExecuteBatch(ctx context.Context, reqs []*BatchRequest, concurrency int) []BatchResult {
+	results := make([]BatchResult, len(reqs))
+	if len(reqs) == 0 {
+		return results
+	}
+	if concurrency <= 0 || concurrency > len(reqs) {
+		concurrency = len(reqs)
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, req := range reqs {
+		if req == nil {
+			results[i].Err = ErrNilBatchRequest
+			continue
+		}
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, req *BatchRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i].Response, results[i].Err = c.executeBatchRequest(ctx, req)
+		}(i, req)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (c *HTTPClient) executeBatchRequest(ctx context.Context, req *BatchRequest) (*Response, error) {
+	// The context may be done while every slot was taken.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	request := c.NewRequest().SetContext(ctx)
+	if req.Body != nil {
+		request.SetBody(req.Body)
+	}
+
+	method := req.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return request.Execute(method, req.URL)
+}
@@ -0,0 +1,86 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteBatch(t *testing.T) {
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			peak := atomic.LoadInt32(&maxRunning)
+			if n <= peak || atomic.CompareAndSwapInt32(&maxRunning, peak, n) {
+				break
+			}
+		}
+
+		if req.URL.Path == "/slow" {
+			<-req.Context().Done()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+		body, _ := io.ReadAll(req.Body)
+		rw.Write([]byte(req.Method + " " + req.URL.Path + " " + string(body)))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Concurrency", func(t *testing.T) {
+		reqs := []*httpclient.BatchRequest{
+			{URL: "/a"},
+			{Method: http.MethodPost, URL: "/b", Body: "body"},
+			{URL: "/c"},
+			{URL: "/d"},
+		}
+
+		results := client.ExecuteBatch(context.Background(), reqs, 2)
+
+		assert.Len(t, results, 4)
+		for _, result := range results {
+			assert.NoError(t, result.Err)
+		}
+		assert.Equal(t, "GET /a ", string(results[0].Response.Body()))
+		assert.Equal(t, "POST /b body", string(results[1].Response.Body()))
+		assert.Equal(t, "GET /d ", string(results[3].Response.Body()))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&maxRunning))
+	})
+
+	t.Run("NilRequest", func(t *testing.T) {
+		results := client.ExecuteBatch(context.Background(), []*httpclient.BatchRequest{{URL: "/a"}, nil}, 0)
+
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "GET /a ", string(results[0].Response.Body()))
+		assert.ErrorIs(t, results[1].Err, httpclient.ErrNilBatchRequest)
+		assert.Nil(t, results[1].Response)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Empty(t, client.ExecuteBatch(context.Background(), nil, -1))
+	})
+
+	t.Run("Cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		reqs := []*httpclient.BatchRequest{{URL: "/slow"}, {URL: "/a"}}
+
+		results := client.ExecuteBatch(ctx, reqs, 1)
+
+		assert.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+		assert.ErrorIs(t, results[1].Err, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ErrBodyNotReplayable is returned when a request with a reader body is retried
+// but the reader can't be rewound nor was fully buffered.
+var ErrBodyNotReplayable = errors.New("request body reader cannot be replayed")
+
+// defaultBodyBufferSize is the default limit of the reader bodies buffered by
+// SetBody to be replayed on retries.
+const defaultBodyBufferSize = 1 << 20
+
+// bodyReader streams a request body and rewinds it between attempts, either by
+// seeking the reader or by replaying what was buffered while it was read.
+type bodyReader struct {
+	io.Reader
+	seeker io.Seeker
+	offset int64
+	sent   bool
+
+	source    io.Reader
+	buffer    []byte
+	maxBuffer int64
+	overflow  bool
+}
+
+// newBodyReader wraps reader, buffering up to maxBuffer bytes of it when it
+// isn't an io.Seeker.
+func newBodyReader(reader io.Reader, maxBuffer int64) *bodyReader {
+	body := &bodyReader{Reader: reader}
+	if seeker, ok := reader.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			body.seeker = seeker
+			body.offset = offset
+			return body
+		}
+	}
+
+	if maxBuffer > 0 {
+		body.source = reader
+		body.maxBuffer = maxBuffer
+		body.Reader = readerFunc(body.readSource)
+	}
+	return body
+}
+
+// readSource reads from the source reader, keeping what was read to replay it.
+func (b *bodyReader) readSource(p []byte) (int, error) {
+	n, err := b.source.Read(p)
+	if !b.overflow {
+		if int64(len(b.buffer)+n) > b.maxBuffer {
+			b.overflow = true
+			b.buffer = nil
+		} else {
+			b.buffer = append(b.buffer, p[:n]...)
+		}
+	}
+	return n, err
+}
+
+func (b *bodyReader) rewind() error {
+	if !b.sent {
+		b.sent = true
+		return nil
+	}
+
+	switch {
+	case b.seeker != nil:
+		_, err := b.seeker.Seek(b.offset, io.SeekStart)
+		return err
+	case b.source != nil && !b.overflow:
+		b.Reader = io.MultiReader(bytes.NewReader(b.buffer), readerFunc(b.readSource))
+		return nil
+	default:
+		return ErrBodyNotReplayable
+	}
+}
+
+// readerFunc adapts a function to io.Reader.
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// rewindBody prepares a streamed body before each attempt.
+func rewindBody(_ *resty.Client, req *resty.Request) error {
+	if body, ok := req.Body.(*bodyReader); ok {
+		return body.rewind()
+	}
+	return nil
+}
+
+// setContentLength applies the Content-Length header to a streamed body,
+// since http.NewRequest can only infer it from in-memory readers.
+func setContentLength(req *http.Request) error {
+	value := req.Header.Get("Content-Length")
+	if value == "" || req.Body == nil || req.Body == http.NoBody || req.ContentLength > 0 {
+		return nil
+	}
+
+	length, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if length == 0 {
+		req.Body = http.NoBody
+	}
+	req.ContentLength = length
+	return nil
+}
+
+// serializedBody returns the body of the request as it's sent, with its content
+// type, or ok false when it can't be read without consuming it, as with io.Reader
+// bodies.
+func (r *Request) serializedBody() (contentType string, body []byte, ok bool, err error) {
+	req := r.restyRequest
+	contentType = req.Header.Get("Content-Type")
+	switch value := req.Body.(type) {
+	case []byte:
+		return contentType, value, true, nil
+	case string:
+		return contentType, []byte(value), true, nil
+	case io.Reader:
+		return contentType, nil, false, nil
+	}
+
+	if resty.IsXMLType(contentType) {
+		body, err = xml.Marshal(req.Body)
+		return contentType, body, true, err
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	if r.jsonEncoder != nil {
+		body, err = r.jsonEncoder(req.Body)
+	} else {
+		body, err = json.Marshal(req.Body)
+	}
+	return contentType, body, true, err
+}
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// Cache stores responses to be reused by WithResponseCache.
+	Cache interface {
+		// Get returns the response stored with the given key, if it's still fresh.
+		Get(key string) (*Response, bool)
+		// Set stores the response with the given key for the ttl duration.
+		Set(key string, resp *Response, ttl time.Duration)
+	}
+
+	// MemoryCache is an in-memory Cache implementation safe for concurrent use.
+	// Expired entries are removed when read, and purged whenever the number of
+	// entries doubles, so it holds at most twice the fresh ones.
+	MemoryCache struct {
+		mu        sync.Mutex
+		entries   map[string]memoryCacheEntry
+		purgeSize int
+	}
+
+	memoryCacheEntry struct {
+		response  *Response
+		expiresAt time.Time
+	}
+)
+
+// memoryCacheMinPurgeSize is the number of entries below which MemoryCache doesn't
+// purge the expired ones.
+const memoryCacheMinPurgeSize = 64
+
+// NewMemoryCache creates an empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryCacheEntry{}, purgeSize: memoryCacheMinPurgeSize}
+}
+
+// Get returns the response stored with the given key, if it's not expired.
+func (c *MemoryCache) Get(key string) (*Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+// Set stores the response with the given key for the ttl duration.
+func (c *MemoryCache) Set(key string, resp *Response, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key] = memoryCacheEntry{response: resp, expiresAt: now.Add(ttl)}
+	if len(c.entries) >= c.purgeSize {
+		c.purge(now)
+	}
+}
+
+// purge removes the expired entries, and sets the size of the next purge to twice
+// the remaining ones.
+func (c *MemoryCache) purge(now time.Time) {
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+
+	c.purgeSize = 2 * len(c.entries)
+	if c.purgeSize < memoryCacheMinPurgeSize {
+		c.purgeSize = memoryCacheMinPurgeSize
+	}
+}
+
+// Len returns the number of entries stored, including the expired ones not yet
+// removed.
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// WithResponseCache caches GET responses honoring the Cache-Control header, as a
+// shared cache: responses are stored for their s-maxage or max-age, unless marked
+// as no-store, no-cache or private, and fresh cached responses are returned without
+// performing the request. Cache hits are registered in the "cache_hit" metric.
+//
+// Requests with a Cache-Control header of no-cache skip the cache lookup, and
+// requests with no-store aren't stored. Responses to authenticated requests, with
+// an Authorization header or credentials added by the transport, such as the ones
+// of WithOAuthTokenSource or WithAuthTransportWrapper, are only stored when marked
+// as public or with s-maxage.
+// Responses with a Vary header aren't stored, as the cache key doesn't include the
+// request headers.
+func WithResponseCache(cache Cache) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.cache = cache
+	}
+}
+
+// cacheKey identifies a GET request by its url, path params and query string.
+func (r *Request) cacheKey(url string) string {
+	return fmt.Sprintf("GET %s?%s", r.withRawQuery(r.resolveURL(url)), r.restyRequest.QueryParam.Encode())
+}
+
+func (r *Request) cachedResponse(key string) (*Response, bool) {
+	if _, ok := cacheDirectives(r.restyRequest.Header)["no-cache"]; ok {
+		return nil, false
+	}
+
+	resp, ok := r.cache.Get(key)
+	if ok {
+		r.emitCounter("cache_hit")
+	}
+	return resp, ok
+}
+
+func (r *Request) cacheResponse(key string, resp *Response) {
+	if resp.StatusCode() != http.StatusOK {
+		return
+	}
+	if _, ok := cacheDirectives(r.restyRequest.Header)["no-store"]; ok {
+		return
+	}
+	if resp.Header().Get("Vary") != "" {
+		return
+	}
+
+	directives := cacheDirectives(resp.Header())
+	for _, directive := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[directive]; ok {
+			return
+		}
+	}
+
+	_, public := directives["public"]
+	sharedMaxAge, shared := directives["s-maxage"]
+	if r.authenticated() && !public && !shared {
+		return
+	}
+
+	maxAge := directives["max-age"]
+	if shared {
+		maxAge = sharedMaxAge
+	}
+	seconds, err := strconv.Atoi(maxAge)
+	if err != nil || seconds <= 0 {
+		return
+	}
+	r.cache.Set(key, resp, time.Duration(seconds)*time.Second)
+}
+
+// authenticated reports whether the request was sent with credentials.
+func (r *Request) authenticated() bool {
+	if r.transportAuth {
+		return true
+	}
+	if raw := r.restyRequest.RawRequest; raw != nil && raw.Header.Get("Authorization") != "" {
+		return true
+	}
+	return r.restyRequest.Header.Get("Authorization") != ""
+}
+
+// cacheDirectives parses the Cache-Control header into a directive/value map.
+func cacheDirectives(header http.Header) map[string]string {
+	directives := map[string]string{}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+	return directives
+}
@@ -0,0 +1,122 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests[req.URL.String()]++
+		switch req.URL.Path {
+		case "/fresh":
+			rw.Header().Set("Cache-Control", "public, max-age=60")
+		case "/no-store":
+			rw.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/private":
+			rw.Header().Set("Cache-Control", "private, max-age=60")
+		case "/max-age":
+			rw.Header().Set("Cache-Control", "max-age=60")
+		case "/shared":
+			rw.Header().Set("Cache-Control", "max-age=60, s-maxage=60")
+		case "/vary":
+			rw.Header().Set("Cache-Control", "public, max-age=60")
+			rw.Header().Set("Vary", "Accept-Language")
+		}
+		rw.Write([]byte(req.URL.String()))
+	}))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithResponseCache(httpclient.NewMemoryCache()),
+	)
+
+	t.Run("Fresh", func(t *testing.T) {
+		for range [2]struct{}{} {
+			resp, err := client.NewRequest().SetAlias("fresh").Get("/fresh")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("/fresh"), resp.Body())
+		}
+		assert.Equal(t, 1, requests["/fresh"])
+		assert.Eventually(t, func() bool { return metrics.counter("fresh.cache_hit") == 1 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("QueryParams", func(t *testing.T) {
+		resp, err := client.NewRequest().SetQueryParams(map[string]string{"page": "2"}).Get("/fresh")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("/fresh?page=2"), resp.Body())
+		assert.Equal(t, 1, requests["/fresh?page=2"])
+	})
+
+	t.Run("NoStore", func(t *testing.T) {
+		for range [2]struct{}{} {
+			_, err := client.NewRequest().Get("/no-store")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, requests["/no-store"])
+	})
+
+	t.Run("WithoutMaxAge", func(t *testing.T) {
+		for range [2]struct{}{} {
+			_, err := client.NewRequest().Get("/")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, requests["/"])
+	})
+
+	t.Run("Private", func(t *testing.T) {
+		for range [2]struct{}{} {
+			_, err := client.NewRequest().Get("/private")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, requests["/private"])
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		for _, language := range []string{"en", "pt"} {
+			resp, err := client.NewRequest().SetHeader("Accept-Language", language).Get("/vary")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("/vary"), resp.Body())
+		}
+		assert.Equal(t, 2, requests["/vary"])
+	})
+
+	t.Run("Authorization", func(t *testing.T) {
+		for _, token := range []string{"alice", "bob"} {
+			_, err := client.NewRequest().SetAuthToken(token).Get("/max-age")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 2, requests["/max-age"])
+
+		for _, token := range []string{"alice", "bob"} {
+			_, err := client.NewRequest().SetAuthToken(token).Get("/shared")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, requests["/shared"])
+	})
+}
+
+func TestMemoryCachePurge(t *testing.T) {
+	cache := httpclient.NewMemoryCache()
+
+	for i := 0; i < 1000; i++ {
+		cache.Set(strconv.Itoa(i), &httpclient.Response{}, time.Nanosecond)
+	}
+	cache.Set("fresh", &httpclient.Response{}, time.Minute)
+
+	assert.Less(t, cache.Len(), 100)
+	_, ok := cache.Get("fresh")
+	assert.True(t, ok)
+}
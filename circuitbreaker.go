@@ -0,0 +1,51 @@
+package httpclient
+
+import (
+	"sync"
+
+	"github.com/slok/goresilience/metrics"
+)
+
+// WithCircuitBreakerStateChange sets a function called when a circuit breaker set with
+// WithCircuitBreaker changes state. The states are "closed", "open" and "halfopen".
+//
+// The function is called synchronously while the circuit breaker is locked, so it
+// must not block nor make requests through the same client.
+func WithCircuitBreakerStateChange(fn func(from, to string)) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.breakerStateChange = fn
+	}
+}
+
+// circuitBreakerRecorder observes the circuit breaker state transitions, which
+// goresilience only reports through its metrics recorder.
+type circuitBreakerRecorder struct {
+	metrics.Recorder
+	client *HTTPClient
+
+	mu    sync.Mutex
+	state string
+}
+
+func newCircuitBreakerRecorder(client *HTTPClient) *circuitBreakerRecorder {
+	return &circuitBreakerRecorder{
+		Recorder: metrics.Dummy,
+		client:   client,
+		state:    "closed",
+	}
+}
+
+func (r *circuitBreakerRecorder) WithID(string) metrics.Recorder {
+	return r
+}
+
+func (r *circuitBreakerRecorder) IncCircuitbreakerState(state string) {
+	r.mu.Lock()
+	from := r.state
+	r.state = state
+	r.mu.Unlock()
+
+	if r.client.breakerStateChange != nil {
+		r.client.breakerStateChange(from, state)
+	}
+}
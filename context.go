@@ -2,9 +2,24 @@ package httpclient
 
 import (
 	"context"
+	"net/url"
 )
 
-const contextRequestIDKey = "request.id"
+const (
+	contextRequestIDKey  = "request.id"
+	contextRetryGuardKey = "request.retry.guard"
+	contextAliasKey      = "request.metrics.alias"
+	contextMetricsKey    = "request.metrics.key"
+	contextDryRunKey     = "request.dry.run"
+	contextBalancedKey   = "request.metrics.balanced.key"
+)
+
+// WithMetricsAlias returns a copy of ctx carrying alias, which replaces the hostname
+// in the metrics of the requests executed with it, as Request.SetAlias does. An
+// alias set on the request takes precedence.
+func WithMetricsAlias(ctx context.Context, alias string) context.Context {
+	return context.WithValue(ctx, contextAliasKey, alias)
+}
 
 // requestID returns a request present on context.
 func requestID(ctx context.Context) string {
@@ -15,3 +30,49 @@ func requestID(ctx context.Context) string {
 
 	return value.(string)
 }
+
+// aliasFromContext returns the metrics alias present on context.
+func aliasFromContext(ctx context.Context) string {
+	alias, _ := ctx.Value(contextAliasKey).(string)
+	return alias
+}
+
+// withMetricsKey returns ctx with the key used to register the metrics of the request.
+func withMetricsKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, contextMetricsKey, key)
+}
+
+// metricsKeyFromContext returns the metrics key present on context, and whether
+// it's present. An empty key means the metrics are disabled.
+func metricsKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(contextMetricsKey).(string)
+	return key, ok
+}
+
+// withBalancedMetricsKey returns ctx with hostKey, returning the metrics key of the
+// request when it's sent to a replica of the load balancer.
+func withBalancedMetricsKey(ctx context.Context, hostKey func(host *url.URL) string) context.Context {
+	return context.WithValue(ctx, contextBalancedKey, hostKey)
+}
+
+// balancedMetricsKey returns the function set by withBalancedMetricsKey, if any.
+func balancedMetricsKey(ctx context.Context) func(host *url.URL) string {
+	hostKey, _ := ctx.Value(contextBalancedKey).(func(host *url.URL) string)
+	return hostKey
+}
+
+// withRetryGuard returns ctx with a retryGuard, preventing the retries of the request.
+func withRetryGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextRetryGuardKey, &retryGuard{})
+}
+
+// requestRetryGuard returns the retryGuard present on context, if any.
+func requestRetryGuard(ctx context.Context) *retryGuard {
+	guard, _ := ctx.Value(contextRetryGuardKey).(*retryGuard)
+	return guard
+}
+
+// retryPrevented reports whether the retries of the request on context are prevented.
+func retryPrevented(ctx context.Context) bool {
+	return requestRetryGuard(ctx) != nil
+}
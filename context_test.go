@@ -24,3 +24,15 @@ var _ = Describe("requestID", func() {
 		Expect(id).To(Equal(""))
 	})
 })
+
+var _ = Describe("aliasFromContext", func() {
+	It("returns the alias set with WithMetricsAlias", func() {
+		ctx := WithMetricsAlias(context.Background(), "users")
+
+		Expect(aliasFromContext(ctx)).To(Equal("users"))
+	})
+
+	It("returns blank string if alias is not present on the context", func() {
+		Expect(aliasFromContext(context.Background())).To(Equal(""))
+	})
+})
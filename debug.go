@@ -0,0 +1,229 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+const redactedHeaderValue = "[REDACTED]"
+
+var defaultDebugRedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+var defaultDebugRedactBodyKeys = []string{"access_token", "refresh_token", "id_token", "client_secret", "password"}
+
+// WithDebug encapsulates the resty library to log the full request and response,
+// including headers and body, through the client logger.
+//
+// The values of the Authorization, Proxy-Authorization, Cookie and Set-Cookie
+// headers are redacted from the logs. Use WithDebugRedactHeaders to change them.
+//
+// More information about this feature: https://github.com/go-resty/resty/tree/v1.x
+func WithDebug(enabled bool) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.SetDebug(enabled)
+		client.resty.OnRequestLog(func(log *resty.RequestLog) error {
+			redactHeaders(log.Header, client.debugRedactHeaders)
+			return nil
+		})
+		client.resty.OnResponseLog(func(log *resty.ResponseLog) error {
+			redactHeaders(log.Header, client.debugRedactHeaders)
+			return nil
+		})
+	}
+}
+
+// WithDebugRedactHeaders replaces the list of headers whose values are redacted
+// from the debug logs enabled by WithDebug.
+func WithDebugRedactHeaders(names ...string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.debugRedactHeaders = names
+	}
+}
+
+// WithDebugRedactBodyKeys replaces the list of JSON object keys whose values are
+// redacted from the response bodies logged by WithResponseBodyLogging.
+func WithDebugRedactBodyKeys(keys ...string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.debugRedactBodyKeys = keys
+	}
+}
+
+// WithDebugWriter routes the request and response dumps enabled by WithDebug to w,
+// keeping them out of the client logger, which still receives every other message.
+func WithDebugWriter(w io.Writer) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.debugWriter = w
+	}
+}
+
+// WithResponseBodyLogging logs up to maxBytes of each response body through the
+// client logger, at debug level, followed by a truncation marker when it's longer.
+// A negative maxBytes logs only the marker. The values of the access_token,
+// refresh_token, id_token, client_secret and password JSON object keys, matched
+// case-insensitively at any depth, are redacted from the logged part of the
+// bodies. Use WithDebugRedactBodyKeys to change them.
+//
+// Like the dumps of WithDebug, the bodies are sent to the WithDebugWriter writer
+// when set.
+func WithResponseBodyLogging(maxBytes int) func(*HTTPClient) {
+	if maxBytes < 0 {
+		maxBytes = 0
+	}
+	return func(client *HTTPClient) {
+		WithResponseMiddleware(func(resp *Response) error {
+			body := resp.body
+			var truncated string
+			if len(body) > maxBytes {
+				truncated = fmt.Sprintf("... [truncated, %d bytes]", len(body))
+				body = body[:maxBytes]
+			}
+			body = redactJSONBody(body, client.debugRedactBodyKeys)
+
+			client.debugLogger().Debugf("response body %s %s (%s): %s%s",
+				resp.request.method, resp.request.url, resp.status, body, truncated)
+			return nil
+		})(client)
+	}
+}
+
+// debugLogger returns the logger receiving the debug messages.
+func (c *HTTPClient) debugLogger() resty.Logger {
+	if c.debugWriter != nil {
+		return newDebugLogger(c.logger, c.debugWriter)
+	}
+	if c.logger == nil {
+		return &LoggerAdapter{Writer: os.Stderr}
+	}
+	return c.logger
+}
+
+// redactJSONBody redacts the values of the object keys in names, at any depth,
+// when body is JSON, keeping the rest of it as is. body may be truncated: a value
+// cut short is redacted up to its end. Other bodies are returned as is.
+func redactJSONBody(body []byte, names []string) []byte {
+	trimmed := bytes.TrimLeft(body, jsonSpace)
+	if len(names) == 0 || len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return body
+	}
+
+	// states holds the state of each open object or array.
+	const (
+		inArray = iota
+		beforeKey
+		beforeValue
+	)
+	var states []int
+	valueRead := func() {
+		if n := len(states); n > 0 && states[n-1] == beforeValue {
+			states[n-1] = beforeKey
+		}
+	}
+
+	var redacted []byte
+	var last int
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+
+		switch {
+		case token == json.Delim('{'):
+			states = append(states, beforeKey)
+		case token == json.Delim('['):
+			states = append(states, inArray)
+		case token == json.Delim('}') || token == json.Delim(']'):
+			states = states[:len(states)-1]
+			valueRead()
+		case states[len(states)-1] != beforeKey:
+			valueRead()
+		case containsFold(names, token.(string)):
+			start := int(decoder.InputOffset())
+			colon := bytes.IndexByte(body[start:], ':')
+			if colon < 0 {
+				break
+			}
+			start += colon + 1
+			start += len(body[start:]) - len(bytes.TrimLeft(body[start:], jsonSpace))
+			end, complete := skipJSONValue(decoder, len(body))
+
+			redacted = append(append(redacted, body[last:start]...), `"`+redactedHeaderValue+`"`...)
+			last = end
+			if !complete {
+				return redacted
+			}
+		default:
+			states[len(states)-1] = beforeValue
+		}
+	}
+
+	if redacted == nil {
+		return body
+	}
+	return append(redacted, body[last:]...)
+}
+
+const jsonSpace = " \t\r\n"
+
+// skipJSONValue reads the next value of decoder, returning the offset where it
+// ends, or length and false when it's cut short.
+func skipJSONValue(decoder *json.Decoder, length int) (int, bool) {
+	depth := 0
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return length, false
+		}
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return int(decoder.InputOffset()), true
+		}
+	}
+}
+
+func containsFold(names []string, s string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// debugLogger sends debug messages to a separate writer.
+type debugLogger struct {
+	resty.Logger
+	debug *LoggerAdapter
+}
+
+func newDebugLogger(logger resty.Logger, w io.Writer) *debugLogger {
+	if logger == nil {
+		logger = &LoggerAdapter{Writer: os.Stderr}
+	}
+	return &debugLogger{Logger: logger, debug: &LoggerAdapter{Writer: w}}
+}
+
+func (l *debugLogger) Debugf(format string, v ...interface{}) {
+	l.debug.Debugf(format, v...)
+}
+
+func redactHeaders(header http.Header, names []string) {
+	for _, name := range names {
+		if _, ok := header[http.CanonicalHeaderKey(name)]; ok {
+			header.Set(name, redactedHeaderValue)
+		}
+	}
+}
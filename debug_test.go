@@ -0,0 +1,156 @@
+package httpclient_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebug(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	t.Run("RedactsDefaultHeaders", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: &buf},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithDebug(true),
+		)
+
+		_, err := client.NewRequest().
+			SetAuthToken("secret-token").
+			SetHeader("X-Api-Key", "api-key").
+			Get("/")
+
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "~~~ REQUEST ~~~")
+		assert.Contains(t, buf.String(), "~~~ RESPONSE ~~~")
+		assert.NotContains(t, buf.String(), "secret-token")
+		assert.Contains(t, buf.String(), "api-key")
+		assert.Contains(t, buf.String(), "[REDACTED]")
+	})
+
+	t.Run("RedactsConfiguredHeaders", func(t *testing.T) {
+		var buf bytes.Buffer
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: &buf},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithDebug(true),
+			httpclient.WithDebugRedactHeaders("X-Api-Key"),
+		)
+
+		_, err := client.NewRequest().
+			SetAuthToken("secret-token").
+			SetHeader("X-Api-Key", "api-key").
+			Get("/")
+
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "secret-token")
+		assert.NotContains(t, buf.String(), "api-key")
+	})
+	t.Run("Writer", func(t *testing.T) {
+		var logs, dumps bytes.Buffer
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: &logs},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithDebug(true),
+			httpclient.WithDebugWriter(&dumps),
+			httpclient.WithRetries(1, time.Millisecond, time.Millisecond),
+		)
+
+		_, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+
+		_, err = client.NewRequest().Get("http://127.0.0.1:1")
+		assert.Error(t, err)
+
+		assert.Contains(t, dumps.String(), "~~~ REQUEST ~~~")
+		assert.NotContains(t, dumps.String(), "WARN")
+		assert.NotContains(t, logs.String(), "~~~ REQUEST ~~~")
+		assert.Contains(t, logs.String(), "WARN")
+	})
+}
+
+func TestResponseBodyLogging(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/token":
+			rw.Write([]byte(`{"access_token":"secret","user":{"Access_Token":"nested"},"expires_in":60}`))
+		default:
+			rw.Write([]byte("0123456789"))
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithResponseBodyLogging(100),
+	)
+
+	_, err := client.NewRequest().Get("/token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG: response body GET /token (200 OK): "+
+		`{"access_token":"[REDACTED]","user":{"Access_Token":"[REDACTED]"},"expires_in":60}`+"\n", buf.String())
+
+	buf.Reset()
+	client = httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDebugRedactBodyKeys("access_token"),
+		httpclient.WithResponseBodyLogging(50),
+	)
+
+	_, err = client.NewRequest().Get("/token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG: response body GET /token (200 OK): "+
+		`{"access_token":"[REDACTED]","user":{"Access_Token":"[REDACTED]"... [truncated, 74 bytes]`+"\n", buf.String())
+
+	buf.Reset()
+	client = httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDebugRedactHeaders("access_token"),
+		httpclient.WithDebugRedactBodyKeys(),
+		httpclient.WithResponseBodyLogging(100),
+	)
+
+	_, err = client.NewRequest().Get("/token")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG: response body GET /token (200 OK): "+
+		`{"access_token":"secret","user":{"Access_Token":"nested"},"expires_in":60}`+"\n", buf.String())
+
+	buf.Reset()
+	client = httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithResponseBodyLogging(4),
+	)
+
+	_, err = client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG: response body GET / (200 OK): 0123... [truncated, 10 bytes]\n", buf.String())
+
+	buf.Reset()
+	client = httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithResponseBodyLogging(-1),
+	)
+
+	_, err = client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "DEBUG: response body GET / (200 OK): ... [truncated, 10 bytes]\n", buf.String())
+}
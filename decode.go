@@ -0,0 +1,34 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+)
+
+// Decode decodes the JSON body of resp into a new T, as Response.JSON does, and
+// returns it:
+//
+//	user, err := httpclient.Decode[User](resp)
+func Decode[T any](resp *Response) (T, error) {
+	var v T
+	if resp == nil {
+		return v, errors.New("decode: nil response")
+	}
+	err := resp.JSON(&v)
+	return v, err
+}
+
+// Get executes a GET request to url with ctx, through the resilience, metrics and
+// middlewares of c, and decodes its JSON body into a new T, returned along with
+// the response. The body is decoded whatever the status code, unless the client
+// is created with WithErrorOnHTTPError.
+func Get[T any](c *HTTPClient, ctx context.Context, url string) (T, *Response, error) {
+	var v T
+	resp, err := c.NewRequest().SetContext(ctx).Get(url)
+	if err != nil {
+		return v, resp, err
+	}
+
+	v, err = Decode[T](resp)
+	return v, resp, err
+}
@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// errDryRun stops a dry run request once it's built.
+var errDryRun = errors.New("dry run")
+
+// dryRun keeps the request built by a dry run.
+type dryRun struct {
+	request *http.Request
+}
+
+// DryRun builds the *http.Request that Execute would send with method and url,
+// without sending it, e.g. for golden file tests of the request construction.
+// The request middlewares, auth and signers are applied, but not the resilience
+// callbacks, such as the circuit breaker and backoff, nor the transport: headers
+// set by the transport, such as the tokens of WithOAUTHTransport, aren't included.
+// The request isn't retried, no metrics are registered and the attempts of the
+// request are left as they were.
+//
+// The body of the returned request can be read without affecting the Request.
+// A reader set with SetBodyReader is consumed to build it, so when the reader
+// isn't an io.Seeker the Request can't be sent afterwards: Execute fails with
+// ErrBodyNotReplayable.
+func (r *Request) DryRun(method, url string) (*http.Request, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.bodyErr != nil {
+		return nil, r.bodyErr
+	}
+
+	r.method = method
+	r.url = url
+	r.pickHost()
+
+	defer r.restyRequest.SetContext(r.restyRequest.Context())
+	for _, middleware := range r.requestMiddlewares {
+		if err := middleware(r); err != nil {
+			return nil, err
+		}
+	}
+
+	// resty counts the attempts across executions, and the load balancer moves
+	// the ones after the first to another replica.
+	defer func(attempt int) { r.restyRequest.Attempt = attempt }(r.restyRequest.Attempt)
+	r.restyRequest.Attempt = 0
+
+	run := &dryRun{}
+	ctx := withMetricsKey(r.restyRequest.Context(), "")
+	r.restyRequest.SetContext(withDryRun(ctx, run))
+	_, err := r.restyRequest.Execute(method, r.withRawQuery(r.balancedURL(url)))
+	if run.request == nil {
+		return nil, err
+	}
+	return run.request, nil
+}
+
+// capture keeps a copy of req, with its body buffered, and stops it with errDryRun.
+func (d *dryRun) capture(req *http.Request) error {
+	body, err := requestBody(req)
+	if err != nil {
+		return err
+	}
+
+	d.request = req.Clone(req.Context())
+	if body != nil {
+		d.request.Body = io.NopCloser(bytes.NewReader(body))
+		d.request.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return errDryRun
+}
+
+// withDryRun returns ctx with run, making the request stop once it's built.
+func withDryRun(ctx context.Context, run *dryRun) context.Context {
+	return context.WithValue(ctx, contextDryRunKey, run)
+}
+
+// requestDryRun returns the dryRun present on context, if any.
+func requestDryRun(ctx context.Context) *dryRun {
+	run, _ := ctx.Value(contextDryRunKey).(*dryRun)
+	return run
+}
@@ -0,0 +1,48 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidBody is returned by Execute, before sending the request, when the body
+// set with a setter such as SetBodyJSON can't be encoded. The encoding error is
+// wrapped along with it.
+var ErrInvalidBody = errors.New("invalid request body")
+
+// HTTPError is returned for non-2xx responses when the client is created with
+// WithErrorOnHTTPError. The Response is still returned along with it.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error: %s", e.Status)
+}
+
+func newHTTPError(resp *Response) *HTTPError {
+	return &HTTPError{
+		StatusCode: resp.statusCode,
+		Status:     resp.status,
+		Body:       resp.body,
+	}
+}
+
+// bodyError wraps the error of a body setter as an ErrInvalidBody.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidBody, e.err)
+}
+
+func (e *bodyError) Is(target error) bool {
+	return target == ErrInvalidBody
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
+}
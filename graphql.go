@@ -0,0 +1,54 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type (
+	// GraphQLError represents an entry of the errors array of a GraphQL response.
+	GraphQLError struct {
+		Message    string                 `json:"message"`
+		Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
+		Path       []interface{}          `json:"path,omitempty"`
+		Extensions map[string]interface{} `json:"extensions,omitempty"`
+	}
+
+	// GraphQLErrorLocation points to the part of the query related to a GraphQLError.
+	GraphQLErrorLocation struct {
+		Line   int `json:"line"`
+		Column int `json:"column"`
+	}
+
+	graphQLRequest struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName,omitempty"`
+		Variables     map[string]interface{} `json:"variables,omitempty"`
+	}
+)
+
+// GraphQL sets the request body as a GraphQL query envelope and marks
+// the request to be sent with the POST method using Send.
+func (r *Request) GraphQL(query string, variables map[string]interface{}) *Request {
+	return r.GraphQLOperation(query, "", variables)
+}
+
+// GraphQLOperation is like GraphQL, also setting the operationName of the
+// envelope, which selects the operation to run when query defines several.
+func (r *Request) GraphQLOperation(query, operationName string, variables map[string]interface{}) *Request {
+	r.restyRequest.Method = http.MethodPost
+	r.restyRequest.SetHeader("Content-Type", "application/json")
+	return r.SetBody(graphQLRequest{Query: query, OperationName: operationName, Variables: variables})
+}
+
+// GraphQLErrors parses the errors array of a GraphQL response body.
+// It returns nil when the body has no errors or isn't a GraphQL response.
+func (r Response) GraphQLErrors() []GraphQLError {
+	var body struct {
+		Errors []GraphQLError `json:"errors"`
+	}
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		return nil
+	}
+	return body.Errors
+}
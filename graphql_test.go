@@ -0,0 +1,70 @@
+package httpclient_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphQL(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		json.NewDecoder(req.Body).Decode(&received)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"data":null,"errors":[{"message":"not found","locations":[{"line":1,"column":3}],"path":["user"]}]}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	resp, err := client.NewRequest().
+		GraphQL("query($id: ID!) { user(id: $id) { name } }", map[string]interface{}{"id": "42"}).
+		Send("/graphql")
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "query($id: ID!) { user(id: $id) { name } }", received["query"])
+		assert.Equal(t, map[string]interface{}{"id": "42"}, received["variables"])
+		assert.NotContains(t, received, "operationName")
+		assert.Equal(t, []httpclient.GraphQLError{{
+			Message:   "not found",
+			Locations: []httpclient.GraphQLErrorLocation{{Line: 1, Column: 3}},
+			Path:      []interface{}{"user"},
+		}}, resp.GraphQLErrors())
+	}
+}
+
+func TestGraphQLOperation(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		json.NewDecoder(req.Body).Decode(&received)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	_, err := client.NewRequest().
+		SetBodyJSON(func() {}).
+		GraphQLOperation("query A { a } query B { b }", "B", nil).
+		Send("/graphql")
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "query A { a } query B { b }", received["query"])
+		assert.Equal(t, "B", received["operationName"])
+		assert.NotContains(t, received, "variables")
+	}
+}
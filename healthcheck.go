@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithHealthCheckedUpstreams spreads the requests across the upstreams at urls in
+// round-robin, as WithLoadBalancer does, routing only to the healthy ones. Every
+// interval, a GET request is sent in the background to the checkPath of each
+// upstream, which is healthy while it responds with a 2xx status within the
+// interval. Upstreams start as healthy and, when none is healthy, every upstream
+// is used.
+//
+// The health checks run until the client is closed with Close.
+func WithHealthCheckedUpstreams(urls []string, checkPath string, interval time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		balancer := newLoadBalancer(urls, RoundRobin)
+		if len(balancer.hosts) == 0 {
+			return
+		}
+
+		client.setLoadBalancer(balancer)
+		client.healthCheck = &healthCheck{
+			balancer: balancer,
+			path:     "/" + strings.TrimPrefix(checkPath, "/"),
+			interval: interval,
+			stop:     make(chan struct{}),
+		}
+	}
+}
+
+// UpstreamStatus returns whether each upstream set with WithHealthCheckedUpstreams
+// or WithLoadBalancer is healthy, by URL. Upstreams without health checks are
+// always healthy.
+func (c *HTTPClient) UpstreamStatus() map[string]bool {
+	if c.balancer == nil {
+		return map[string]bool{}
+	}
+	return c.balancer.status()
+}
+
+type healthCheck struct {
+	balancer  *loadBalancer
+	path      string
+	interval  time.Duration
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+func (h *healthCheck) start(client *http.Client) {
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+
+		for {
+			h.probeAll(client)
+			select {
+			case <-h.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (h *healthCheck) probeAll(client *http.Client) {
+	var wg sync.WaitGroup
+	for i := range h.balancer.hosts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			h.balancer.setHealthy(i, h.probe(client, h.balancer.hosts[i].String()+h.path))
+		}(i)
+	}
+	wg.Wait()
+}
+
+// probe returns whether url responds with a 2xx status within the interval.
+func (h *healthCheck) probe(client *http.Client, url string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-h.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= 200 && resp.StatusCode <= 299
+}
+
+func (h *healthCheck) close() {
+	h.closeOnce.Do(func() {
+		close(h.stop)
+	})
+}
@@ -0,0 +1,146 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+// WithHedging sends up to maxHedges extra copies of idempotent requests when
+// the previous one doesn't respond within delay, reducing tail latency. The
+// first successful response is used and the outstanding requests are cancelled.
+// Each extra request increments the "<alias>.hedge_fired" metric.
+//
+// Requests with a body set by SetBodyReader or another io.Reader are not hedged.
+// Hedged requests are copies of the original request, with their own result and
+// error values; the values of the winning request are copied to the original ones.
+// Settings made directly on RestyRequest that resty doesn't expose, such as
+// ForceContentType or multipart files, aren't copied to them.
+func WithHedging(delay time.Duration, maxHedges int) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.hedgeDelay = delay
+		client.maxHedges = maxHedges
+	}
+}
+
+func (r *Request) shouldHedge(method string) bool {
+	if r.maxHedges <= 0 || r.streamLine != nil || !isIdempotent(method) {
+		return false
+	}
+	_, isReader := r.restyRequest.Body.(io.Reader)
+	return !isReader
+}
+
+type hedgeResult struct {
+	request  *resty.Request
+	response *resty.Response
+	err      error
+}
+
+// executeHedged sends copies of the request until one succeeds or all fail.
+// The original resty request is never sent, so it's safe to read once this returns.
+func (r *Request) executeHedged(ctx context.Context, method, url string) (*resty.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, r.maxHedges+1)
+	send := func() {
+		request := r.cloneRestyRequest().SetContext(ctx)
+		go func() {
+			response, err := request.Execute(method, url)
+			results <- hedgeResult{request: request, response: response, err: err}
+		}()
+	}
+
+	send()
+	sent, pending := 1, 1
+
+	timer := time.NewTimer(r.hedgeDelay)
+	defer timer.Stop()
+
+	var result hedgeResult
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if sent <= r.maxHedges {
+				send()
+				sent++
+				pending++
+				r.emitCounter("hedge_fired")
+				timer.Reset(r.hedgeDelay)
+			}
+		case result = <-results:
+			pending--
+			if result.err == nil {
+				pending = 0
+			}
+		}
+	}
+
+	attempts := result.request.Attempt
+	if attempts < 1 {
+		attempts = 1
+	}
+	r.attempts += sent - 1 + attempts
+	r.copyResult(result.request)
+	return result.response, result.err
+}
+
+// cloneRestyRequest builds a copy of the request, through the resty API, so it
+// can be sent concurrently. It covers the state set through Request; settings
+// that resty keeps private, such as ForceContentType, SetOutput or multipart
+// files set with RestyRequest, aren't copied.
+func (r *Request) cloneRestyRequest() *resty.Request {
+	original := r.restyRequest
+	clone := r.restyClient.R().
+		SetQueryParamsFromValues(original.QueryParam).
+		SetFormDataFromValues(original.FormData).
+		SetPathParams(original.PathParams).
+		SetRawPathParams(original.RawPathParams).
+		SetCookies(original.Cookies).
+		SetBody(original.Body).
+		SetDebug(original.Debug)
+	// Headers are copied as they are, since the setters canonicalize their names.
+	clone.Header = original.Header.Clone()
+	if original.UserInfo != nil {
+		clone.SetBasicAuth(original.UserInfo.Username, original.UserInfo.Password)
+	}
+	if original.Token != "" {
+		clone.SetAuthToken(original.Token)
+	}
+	if original.AuthScheme != "" {
+		clone.SetAuthScheme(original.AuthScheme)
+	}
+	if original.SRV != nil {
+		clone.SetSRV(original.SRV)
+	}
+	if original.Result != nil {
+		clone.SetResult(newOf(original.Result))
+	}
+	if original.Error != nil {
+		clone.SetError(newOf(original.Error))
+	}
+	return clone
+}
+
+// copyResult copies the decoded result and error of a hedged request back to
+// the pointers set on the original request.
+func (r *Request) copyResult(hedged *resty.Request) {
+	original := r.restyRequest
+	if original.Result != nil && hedged.Result != nil {
+		reflect.ValueOf(original.Result).Elem().Set(reflect.ValueOf(hedged.Result).Elem())
+		hedged.Result = original.Result
+	}
+	if original.Error != nil && hedged.Error != nil {
+		reflect.ValueOf(original.Error).Elem().Set(reflect.ValueOf(hedged.Error).Elem())
+		hedged.Error = original.Error
+	}
+}
+
+// newOf returns a pointer to a new zero value of the type pointed by v.
+func newOf(v interface{}) interface{} {
+	return reflect.New(reflect.TypeOf(v).Elem()).Interface()
+}
@@ -0,0 +1,142 @@
+package httpclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedging(t *testing.T) {
+	var calls int32
+	cancelled := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-req.Context().Done():
+				select {
+				case cancelled <- struct{}{}:
+				default:
+				}
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"message":"hedged"}`))
+	}))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithHedging(50*time.Millisecond, 2),
+	)
+
+	t.Run("Idempotent", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		result := &struct {
+			Message string `json:"message"`
+		}{}
+
+		start := time.Now()
+		resp, err := client.NewRequest().SetAlias("hedged").SetResult(result).Get("/")
+
+		assert.NoError(t, err)
+		assert.Less(t, time.Since(start), 500*time.Millisecond)
+		assert.Equal(t, "hedged", result.Message)
+		assert.Equal(t, result, resp.Result())
+		assert.Equal(t, 2, resp.Attempts())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.Eventually(t, func() bool { return metrics.counter("hedged.hedge_fired") == 1 }, time.Second, 10*time.Millisecond)
+
+		select {
+		case <-cancelled:
+		case <-time.After(time.Second):
+			t.Error("outstanding request was not cancelled")
+		}
+	})
+
+	t.Run("NotIdempotent", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := client.NewRequest().SetContext(ctx).Post("/")
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("BodyReader", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := client.NewRequest().
+			SetContext(ctx).
+			SetBodyReader(strings.NewReader("body"), -1).
+			Put("/")
+
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestHedgingRequestState(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-req.Context().Done():
+			case <-time.After(time.Second):
+			}
+			return
+		}
+		username, password, _ := req.BasicAuth()
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusConflict)
+		json.NewEncoder(rw).Encode(map[string]string{
+			"message": strings.Join([]string{username, password, req.URL.Query().Get("q"), req.PostFormValue("name")}, ","),
+		})
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithHedging(50*time.Millisecond, 1),
+	)
+
+	result := &struct {
+		Message string `json:"message"`
+	}{}
+	apiError := &struct {
+		Message string `json:"message"`
+	}{}
+	resp, err := client.NewRequest().
+		SetBasicAuth("user", "secret").
+		SetQueryParams(map[string]string{"q": "query"}).
+		SetFormURLEncoded(url.Values{"name": {"form"}}).
+		SetResult(result).
+		SetError(apiError).
+		Put("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Equal(t, http.StatusConflict, resp.StatusCode())
+	assert.Empty(t, result.Message)
+	assert.Equal(t, "user,secret,query,form", apiError.Message)
+	assert.Equal(t, apiError, resp.Error())
+}
@@ -3,31 +3,91 @@ package httpclient
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
 	"net/url"
+	"os"
+	"sort"
+	"syscall"
 	"time"
 
 	resty "github.com/go-resty/resty/v2"
 	"github.com/slok/goresilience/circuitbreaker"
 	goresilienceErrors "github.com/slok/goresilience/errors"
-	"github.com/slok/goresilience/retry"
+	"github.com/slok/goresilience/metrics"
+	"golang.org/x/net/proxy"
 	"golang.org/x/oauth2"
 	cc "golang.org/x/oauth2/clientcredentials"
 )
 
 var ErrCircuitOpen = goresilienceErrors.ErrCircuitOpen
 
+// ErrTimeout is returned when a request exceeds the timeout set with WithTimeoutResilience.
+var ErrTimeout = goresilienceErrors.ErrTimeout
+
 type (
 	Callback func(func() (*Response, error)) (*Response, error)
 
+	// contextCallback is a Callback that also receives the request context.
+	contextCallback func(context.Context, func(context.Context) (*Response, error)) (*Response, error)
+
 	Opt func(*HTTPClient)
 
 	HTTPClient struct {
-		resty         *resty.Client
-		hostURL       *url.URL
-		metrics       Metrics
-		callbackChain Callback
+		resty               *resty.Client
+		logger              resty.Logger
+		hostURL             *url.URL
+		balancer            *loadBalancer
+		healthCheck         *healthCheck
+		bodyBufferSize      int64
+		sharedTransport     bool
+		tokenRetries        int
+		tokenRetryWait      time.Duration
+		idempotentRetries   bool
+		jsonDecoder         func([]byte, interface{}) error
+		jsonEncoder         func(interface{}) ([]byte, error)
+		metrics             Metrics
+		metricsKeyFormatter func(method, url, alias string) string
+		latencyBuckets      []float64
+		syncMetrics         bool
+		metricsSampleRate   float64
+		random              func() float64
+		queryArrayFormat    ArrayFormat
+		cache               Cache
+		transportAuth       bool
+		clock               func() time.Time
+		baseContext         context.Context
+		hostTimeouts        map[string]time.Duration
+		name                string
+		errorOnHTTPError    bool
+		hedgeDelay          time.Duration
+		maxHedges           int
+		clientTrace         *httptrace.ClientTrace
+		traceMetrics        bool
+		connMetrics         bool
+		transportMetrics    bool
+		metricsWrapped      bool
+		callbackChain       contextCallback
+		requestMiddlewares  []func(*Request) error
+		responseMiddlewares []func(*Response) error
+		executeHooks        []func(*Request, *Response, error)
+		slowThreshold       time.Duration
+		slowRequest         func(*Response)
+		signers             []RequestSigner
+		debugRedactHeaders  []string
+		debugRedactBodyKeys []string
+		debugWriter         io.Writer
+		breakerStateChange  func(from, to string)
+		dialer              *net.Dialer
+		transportOptions    []func(*http.Transport)
+		transportWrappers   []func(http.RoundTripper) http.RoundTripper
 	}
 )
 
@@ -38,28 +98,131 @@ type (
 //	logger: interface is used to log request and response details.
 //	options: specifies options to HTTPClient.
 func NewHTTPClient(logger resty.Logger, options ...Opt) *HTTPClient {
-	return newClient(resty.New().SetLogger(logger).GetClient(), options...)
+	return newClient(resty.New().GetClient(), logger, options...)
+}
+
+// NewHTTPClientWithClient instantiates a new HTTPClient that sends requests with
+// the given http.Client, reusing its transport, connection pool and cookie jar.
+//
+// Options are applied to a copy of the http.Client, so settings such as WithTimeout
+// don't change the given one. Options that tune the transport, such as
+// WithDialTimeout, apply to a copy of it when it's an *http.Transport, and to the
+// shared transport otherwise. The logger is set with WithRestyLogger.
+//
+// Parameters:
+//
+//	httpClient: the http.Client used to send the requests.
+//	options: specifies options to HTTPClient.
+func NewHTTPClientWithClient(httpClient *http.Client, options ...Opt) *HTTPClient {
+	customClient := *httpClient
+	return newClient(&customClient, nil, append([]Opt{withSharedTransport}, options...)...)
 }
 
-func newClient(customClient *http.Client, options ...Opt) *HTTPClient {
+// withSharedTransport makes the transport options apply to a copy of the transport.
+func withSharedTransport(client *HTTPClient) {
+	client.sharedTransport = true
+}
+
+func newClient(customClient *http.Client, logger resty.Logger, options ...Opt) *HTTPClient {
 	client := &HTTPClient{
-		resty:         resty.NewWithClient(customClient),
-		callbackChain: noopCallback,
+		resty:               resty.NewWithClient(customClient),
+		logger:              logger,
+		clock:               time.Now,
+		random:              rand.Float64,
+		metricsSampleRate:   1,
+		bodyBufferSize:      defaultBodyBufferSize,
+		callbackChain:       noopCallback,
+		debugRedactHeaders:  defaultDebugRedactHeaders,
+		debugRedactBodyKeys: defaultDebugRedactBodyKeys,
+	}
+	if logger != nil {
+		client.resty.SetLogger(logger)
 	}
 
 	for _, option := range options {
 		option(client)
 	}
 
+	if client.debugWriter != nil {
+		client.resty.SetLogger(newDebugLogger(logger, client.debugWriter))
+	}
+
+	client.resty.OnBeforeRequest(rewindBody)
+	client.resty.SetPreRequestHook(client.preRequest)
+
+	client.applyTransportOptions()
+	for _, wrap := range client.transportWrappers {
+		client.setTransport(wrap(client.GetClient().Transport))
+	}
+
+	if client.healthCheck != nil {
+		client.healthCheck.start(client.GetClient())
+	}
+
 	return client
 }
 
+// preRequest adjusts the fully built request before it is sent.
+func (c *HTTPClient) preRequest(restyClient *resty.Client, req *http.Request) error {
+	if err := setContentLength(req); err != nil {
+		return err
+	}
+
+	if len(c.signers) > 0 {
+		if err := c.signRequest(restyClient, req); err != nil {
+			return err
+		}
+	}
+
+	if run := requestDryRun(req.Context()); run != nil {
+		return run.capture(req)
+	}
+	return nil
+}
+
 // GetClient returns the current http.Client.
 func (c *HTTPClient) GetClient() *http.Client {
 	return c.resty.GetClient()
 }
 
+// Now returns the current time of the client clock, set with WithClock.
+func (c *HTTPClient) Now() time.Time {
+	return c.clock()
+}
+
+// Close releases the resources of the client: it stops the background workers
+// started by options, such as the health checks of WithHealthCheckedUpstreams,
+// and closes the idle connections of the transport. It's safe to call more than
+// once, and requests can still be made after it, opening new connections.
+func (c *HTTPClient) Close() error {
+	if c.healthCheck != nil {
+		c.healthCheck.close()
+	}
+
+	c.CloseIdleConnections()
+	return nil
+}
+
+// CloseIdleConnections closes the idle connections of the transport, forcing the
+// next requests to open fresh ones, e.g. after rotating credentials. Connections
+// in use are not interrupted.
+func (c *HTTPClient) CloseIdleConnections() {
+	if transport := baseTransport(c.GetClient().Transport); transport != nil {
+		transport.CloseIdleConnections()
+		return
+	}
+	c.GetClient().CloseIdleConnections()
+}
+
 func (c *HTTPClient) chainCallback(newCallback Callback) {
+	c.chainContextCallback(func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+		return newCallback(func() (*Response, error) {
+			return fn(ctx)
+		})
+	})
+}
+
+func (c *HTTPClient) chainContextCallback(newCallback contextCallback) {
 	previousCallback := c.callbackChain
 
 	if previousCallback == nil {
@@ -67,9 +230,9 @@ func (c *HTTPClient) chainCallback(newCallback Callback) {
 		return
 	}
 
-	c.callbackChain = func(fn func() (*Response, error)) (*Response, error) {
-		return newCallback(func() (*Response, error) {
-			return previousCallback(fn)
+	c.callbackChain = func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+		return newCallback(ctx, func(ctx context.Context) (*Response, error) {
+			return previousCallback(ctx, fn)
 		})
 	}
 }
@@ -78,6 +241,70 @@ func (c *HTTPClient) setTransport(transport http.RoundTripper) {
 	c.resty.SetTransport(transport)
 }
 
+// netDialer returns the dialer configured by the dial options, creating one
+// with the default transport settings on the first call.
+func (c *HTTPClient) netDialer() *net.Dialer {
+	if c.dialer == nil {
+		c.dialer = &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 15 * time.Second,
+		}
+	}
+	return c.dialer
+}
+
+// applyTransportOptions applies the options that tune the *http.Transport used by
+// the client. They run after every other option, so they work regardless of the
+// order in which transport options are given.
+func (c *HTTPClient) applyTransportOptions() {
+	if c.dialer == nil && len(c.transportOptions) == 0 {
+		return
+	}
+
+	transport := c.httpTransport()
+	if transport == nil {
+		return
+	}
+	if c.dialer != nil {
+		transport.DialContext = c.dialer.DialContext
+	}
+	for _, option := range c.transportOptions {
+		option(transport)
+	}
+}
+
+// httpTransport returns the *http.Transport the client transport is based on,
+// or nil when it uses another kind of http.RoundTripper.
+func (c *HTTPClient) httpTransport() *http.Transport {
+	if c.GetClient().Transport == http.DefaultTransport {
+		c.setTransport(http.DefaultTransport.(*http.Transport).Clone())
+	}
+	if transport, ok := c.GetClient().Transport.(*http.Transport); ok && c.sharedTransport {
+		c.setTransport(transport.Clone())
+		c.sharedTransport = false
+	}
+	return baseTransport(c.GetClient().Transport)
+}
+
+func baseTransport(roundTripper http.RoundTripper) *http.Transport {
+	switch transport := roundTripper.(type) {
+	case *http.Transport:
+		return transport
+	case *Transport:
+		return baseTransport(transport.RoundTripper)
+	case *bodyLimitTransport:
+		return baseTransport(transport.RoundTripper)
+	case *deadlineTransport:
+		return baseTransport(transport.RoundTripper)
+	case *oauthTransport:
+		return baseTransport(transport.RoundTripper)
+	case interface{ Unwrap() http.RoundTripper }:
+		return baseTransport(transport.Unwrap())
+	default:
+		return nil
+	}
+}
+
 func NewDefaultTransport(transportTimeout time.Duration) http.RoundTripper {
 	return &Transport{
 		RoundTripper: &http.Transport{
@@ -110,6 +337,119 @@ func WithDefaultTransport(transportTimeout time.Duration) func(*HTTPClient) {
 	}
 }
 
+// WithDialTimeout sets the dialer timeouts of the transport independently:
+// connect limits the time spent establishing a TCP connection, and keepAlive
+// sets the interval between keep-alive probes of the active connections.
+// The other transport settings are preserved.
+//
+// More information about timeouts: net.Dialer.
+func WithDialTimeout(connect, keepAlive time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		dialer := client.netDialer()
+		dialer.Timeout = connect
+		dialer.KeepAlive = keepAlive
+	}
+}
+
+// WithDialControl sets the Control function of the dialer, which is called after
+// creating each network connection and before dialing, e.g. to set socket options
+// such as TCP_USER_TIMEOUT or SO_MARK. An error returned by control fails the
+// connection. The other transport settings are preserved.
+//
+// More information about the control function: net.Dialer.
+func WithDialControl(control func(network, address string, c syscall.RawConn) error) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.netDialer().Control = control
+	}
+}
+
+// WithResponseHeaderTimeout limits the time spent waiting for the response headers
+// after the request is fully written, failing fast on stalled upstreams while still
+// allowing long response bodies. The other transport settings are preserved.
+//
+// More information about this timeout: http.Transport.
+func WithResponseHeaderTimeout(d time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			transport.ResponseHeaderTimeout = d
+		})
+	}
+}
+
+// WithExpectContinueTimeout sets how long to wait for the server's first response
+// headers after sending the request headers, when the request has an
+// "Expect: 100-continue" header. Zero means no timeout and the body is sent
+// immediately. See Request.SetExpectContinue.
+func WithExpectContinueTimeout(d time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			transport.ExpectContinueTimeout = d
+		})
+	}
+}
+
+// WithKeepAlivesDisabled disables HTTP keep-alives, so every request opens a new
+// connection that is closed after the response, e.g. for one-shot command line
+// tools. The other transport settings are preserved.
+func WithKeepAlivesDisabled() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			transport.DisableKeepAlives = true
+		})
+	}
+}
+
+// WithMaxConnsPerHost limits the number of connections to each host, counting the
+// ones dialing, active and idle. Once the limit is reached, the requests to the
+// host wait for a free connection. Zero means no limit. The other transport
+// settings are preserved.
+//
+// The time spent waiting for a connection counts toward the request timeout and
+// the context deadline, so requests queued behind slow ones may time out before
+// they are sent.
+func WithMaxConnsPerHost(n int) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			transport.MaxConnsPerHost = n
+		})
+	}
+}
+
+// WithTLSServerName sets the server name sent with SNI and used to verify the
+// upstream certificate, e.g. when connecting to an IP address instead of a
+// hostname. The other transport settings are preserved.
+func WithTLSServerName(name string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.ServerName = name
+		})
+	}
+}
+
+// WithTLSVersions limits the TLS versions negotiated with the upstreams, such as
+// tls.VersionTLS13 for both to allow TLS 1.3 only. A zero max negotiates up to the
+// highest version supported. The other transport settings are preserved.
+//
+// It panics when max is not zero and lower than min.
+func WithTLSVersions(min, max uint16) func(*HTTPClient) {
+	if max != 0 && min > max {
+		panic(fmt.Sprintf("httpclient: min TLS version %#04x is greater than max %#04x", min, max))
+	}
+
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.MinVersion = min
+			transport.TLSClientConfig.MaxVersion = max
+		})
+	}
+}
+
 // WithTransport configures the client to use a custom *http.Transport
 // More information about transport: [net/http.Transport]
 func WithTransport(transport *http.Transport) func(*HTTPClient) {
@@ -121,19 +461,34 @@ func WithTransport(transport *http.Transport) func(*HTTPClient) {
 // WithOAUTHTransport allows the client to make OAuth HTTP requests with custom timeout.
 // This timeout limits the time spent establishing a TCP connection.
 //
-// The oauth2.Transport adds an Authorization header with a token
+// The transport adds an Authorization header with a token
 // using clientcredentials.Config information.
 //
 // More information about timeout: net.Dialer.
 //
 // More information about the fields used to create the token: clientcredentials.Config.
 func WithOAUTHTransport(conf cc.Config, transportTimeout time.Duration) func(*HTTPClient) {
+	return WithOAuthTokenSource(conf.TokenSource(context.Background()), transportTimeout)
+}
+
+// WithOAuthTokenSource allows the client to make OAuth HTTP requests with tokens from
+// an existing oauth2.TokenSource, such as one refreshed from a metadata server,
+// instead of the client credentials grant of WithOAUTHTransport. The transport
+// timeout limits the time spent establishing a TCP connection.
+//
+// The transport adds an Authorization header with a token from src, which
+// should cache its tokens, e.g. with oauth2.ReuseTokenSource. Failures to get a
+// token return ErrTokenAcquisition, and can be retried with WithOAuthTokenRetries.
+//
+// More information about timeout: net.Dialer.
+func WithOAuthTokenSource(src oauth2.TokenSource, transportTimeout time.Duration) func(*HTTPClient) {
 	return func(client *HTTPClient) {
-		transport := &oauth2.Transport{
-			Source: conf.TokenSource(context.Background()),
-			Base:   NewDefaultTransport(transportTimeout),
+		transport := &oauthTransport{
+			RoundTripper: NewDefaultTransport(transportTimeout),
+			source:       &tokenSource{source: src, client: client},
 		}
 		client.setTransport(transport)
+		client.transportAuth = true
 	}
 }
 
@@ -165,6 +520,168 @@ func WithDefaultTransportWithProxy(proxyURL *url.URL) func(*HTTPClient) {
 	}
 }
 
+// WithProxyFunc sets a function to return the proxy for each request, allowing
+// different hosts to be routed through different proxies. As in http.Transport,
+// returning a nil URL means the request shouldn't use a proxy.
+//
+// It applies to the transport configured by the other options, as long as it is
+// based on http.Transport.
+func WithProxyFunc(fn func(*http.Request) (*url.URL, error)) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			transport.Proxy = fn
+		})
+	}
+}
+
+// WithSOCKS5Proxy routes every connection through the SOCKS5 proxy at the given
+// address, using auth when it isn't nil. The connections to the proxy are
+// established by the dialer of the configured transport, so its other settings,
+// including TLS, are preserved.
+//
+// More information about the dialer: golang.org/x/net/proxy.
+func WithSOCKS5Proxy(address string, auth *proxy.Auth) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			var forward contextDialerFunc = (&net.Dialer{}).DialContext
+			if transport.DialContext != nil {
+				forward = transport.DialContext
+			}
+
+			dialer, err := proxy.SOCKS5("tcp", address, auth, forward)
+			transport.Proxy = nil
+			transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				if err != nil {
+					return nil, err
+				}
+				return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+			}
+		})
+	}
+}
+
+// contextDialerFunc adapts a DialContext function to proxy.Dialer and proxy.ContextDialer.
+type contextDialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f contextDialerFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+func (f contextDialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+// WithTransportWrapper wraps the transport configured by the other options with the
+// RoundTripper returned by wrap, e.g. to add logging or metrics middlewares.
+// Wrappers nest in registration order: the first one wraps the actual transport,
+// and the last one receives the requests first. A wrapper with an
+// Unwrap() http.RoundTripper method, returning the RoundTripper it wraps, lets
+// CloseIdleConnections reach the transport under it.
+func WithTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportWrappers = append(client.transportWrappers, wrap)
+	}
+}
+
+// WithAuthTransportWrapper is like WithTransportWrapper, for wrappers that add
+// credentials to the requests, such as the one of ntlm.WithNTLMAuth. Their
+// responses are handled by WithResponseCache as the ones of authenticated requests.
+func WithAuthTransportWrapper(wrap func(http.RoundTripper) http.RoundTripper) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportAuth = true
+		WithTransportWrapper(wrap)(client)
+	}
+}
+
+// WithTransportMetrics records transport-level metrics with the Metrics set with
+// WithMetrics, under the metric key of each request:
+//
+//	<key>.request_bytes: the size of the request body sent
+//	<key>.response_bytes: the size of the response body read, once it's closed
+//	<key>.conn_reused: 1 when the request reused a connection, 0 otherwise
+//
+// All of them are pushed to series, in a separate goroutine unless
+// WithSynchronousMetrics is set, as the other metrics of the requests.
+func WithTransportMetrics() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportMetrics = true
+		client.wrapMetricsTransport()
+	}
+}
+
+// wrapMetricsTransport wraps the transport, once, with the Transport recording the
+// metrics of WithTransportMetrics and WithConnectionReuseMetrics.
+func (c *HTTPClient) wrapMetricsTransport() {
+	if c.metricsWrapped {
+		return
+	}
+	c.metricsWrapped = true
+	c.transportWrappers = append(c.transportWrappers, func(transport http.RoundTripper) http.RoundTripper {
+		if c.metrics == nil {
+			return transport
+		}
+		return &Transport{
+			RoundTripper: transport,
+			headers:      []contextHeader{},
+			metrics:      c.metrics,
+			sizeMetrics:  c.transportMetrics,
+			connMetrics:  c.connMetrics,
+			syncMetrics:  c.syncMetrics,
+		}
+	})
+}
+
+// WithDeadlinePropagation sets the time remaining until the deadline of the request
+// context, in milliseconds, on header, e.g. "X-Request-Timeout", so upstreams can
+// bound their own work. It's computed for every attempt, including the timeouts
+// set with WithTimeoutPerHost, and isn't sent when the context has no deadline.
+func WithDeadlinePropagation(header string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportWrappers = append(client.transportWrappers, func(transport http.RoundTripper) http.RoundTripper {
+			return &deadlineTransport{RoundTripper: transport, header: header}
+		})
+	}
+}
+
+// WithMaxResponseBodySize limits the size of the response bodies to maxBytes.
+// Requests fail with ErrResponseTooLarge as soon as the limit is exceeded,
+// either by the Content-Length header or while reading the body, without
+// buffering the rest of it.
+func WithMaxResponseBodySize(maxBytes int64) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.transportWrappers = append(client.transportWrappers, func(transport http.RoundTripper) http.RoundTripper {
+			return &bodyLimitTransport{RoundTripper: transport, maxBytes: maxBytes}
+		})
+	}
+}
+
+// WithContextHeaders sets request headers from the values of the request context,
+// generalizing the X-Request-ID propagation: mapping associates each context key
+// with the header it's sent as. Headers are only set for the keys present on the
+// context, with string values sent as is and other values formatted with fmt.
+func WithContextHeaders(mapping map[interface{}]string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		headers := make([]contextHeader, 0, len(mapping))
+		for key, header := range mapping {
+			headers = append(headers, contextHeader{key: key, header: header})
+		}
+		client.transportWrappers = append(client.transportWrappers, func(transport http.RoundTripper) http.RoundTripper {
+			return &Transport{RoundTripper: transport, headers: headers}
+		})
+	}
+}
+
+// WithBodyBufferSize sets the limit of the io.Reader bodies set with SetBody that are
+// kept in memory to be replayed on retries, which is 1 MiB by default. Buffering
+// uses up to maxBytes per request in flight; retries of larger bodies fail with
+// ErrBodyNotReplayable. A limit of zero disables the buffering. Readers that
+// implement io.Seeker are rewound instead of buffered.
+func WithBodyBufferSize(maxBytes int64) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.bodyBufferSize = maxBytes
+	}
+}
+
 // WithTimeout encapsulates the resty library to set a custom request timeout.
 //
 // More information about this feature: https://github.com/go-resty/resty/tree/v1.x
@@ -174,6 +691,17 @@ func WithTimeout(timeout time.Duration) func(*HTTPClient) {
 	}
 }
 
+// WithTimeoutPerHost sets request timeouts by target hostname, e.g. "api.example.com",
+// for clients shared across upstreams with different latency profiles. The timeout
+// covers the whole request, including retries, by wrapping the request context.
+// Hosts not in the map use the global timeout set with WithTimeout, which also
+// remains an upper bound for every attempt.
+func WithTimeoutPerHost(timeouts map[string]time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.hostTimeouts = timeouts
+	}
+}
+
 // WithUserAgent encapsulates the resty library to set a custom user agent to requests.
 //
 // More information about this feature: https://github.com/go-resty/resty/tree/v1.x
@@ -183,6 +711,19 @@ func WithUserAgent(userAgent string) func(*HTTPClient) {
 	}
 }
 
+// WithUserAgentAppend appends token to the user agent configured by the options
+// given before it, such as WithUserAgent, separated by a space, as in
+// "base-ua mycomponent/1.2". The token alone is used when no user agent was set.
+func WithUserAgentAppend(token string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		userAgent := token
+		if base := client.resty.Header.Get("User-Agent"); base != "" {
+			userAgent = base + " " + token
+		}
+		client.resty.SetHeader("User-Agent", userAgent)
+	}
+}
+
 // WithBasicAuth encapsulates the resty library to provide basic authentication.
 //
 // More information about this feature: https://github.com/go-resty/resty/tree/v1.x
@@ -201,6 +742,30 @@ func WithAuthToken(token string) func(*HTTPClient) {
 	}
 }
 
+// WithAuthScheme sets the scheme of the Authorization header of the tokens set with
+// WithAuthToken, WithAuthTokenProvider or Request.SetAuthToken, such as "Token" or
+// "JWT", instead of "Bearer".
+func WithAuthScheme(scheme string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.SetAuthScheme(scheme)
+	}
+}
+
+// WithAuthTokenProvider sets the bearer token of every request to the one returned by
+// fn, such as a token read from a rotating file, resolved with the request context
+// each time the request is executed. An error returned by fn aborts the request
+// without sending it, wrapped in the returned error.
+func WithAuthTokenProvider(fn func(ctx context.Context) (string, error)) func(*HTTPClient) {
+	return WithRequestMiddleware(func(r *Request) error {
+		token, err := fn(r.restyRequest.Context())
+		if err != nil {
+			return fmt.Errorf("auth token provider: %w", err)
+		}
+		r.restyRequest.SetAuthToken(token)
+		return nil
+	})
+}
+
 // WithCookie encapsulates the resty library to set a cookie to client instance.
 //
 // More information about this feature: https://github.com/go-resty/resty/tree/v1.x
@@ -211,6 +776,76 @@ func WithCookie(name, value string) func(*HTTPClient) {
 	}
 }
 
+// WithJSONDecoder sets the function used to decode JSON response bodies, instead of
+// encoding/json, e.g. a strict decoder that disallows unknown fields or a faster
+// one. It's used by Response.JSON and Response.Unmarshal, and to decode the
+// results set with SetResult and SetError.
+func WithJSONDecoder(fn func([]byte, interface{}) error) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.jsonDecoder = fn
+		client.resty.SetJSONUnmarshaler(fn)
+	}
+}
+
+// WithJSONEncoder sets the function used to encode JSON request bodies, instead of
+// encoding/json, e.g. one that doesn't escape HTML characters. It's used by
+// SetBodyJSON and to encode the structs and maps set with SetBody.
+func WithJSONEncoder(fn func(interface{}) ([]byte, error)) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.jsonEncoder = fn
+		client.resty.SetJSONMarshaler(fn)
+	}
+}
+
+// WithRestyLogger sets the logger of the request and response details, as the one
+// given to NewHTTPClient does, e.g. for the clients of NewHTTPClientWithClient.
+func WithRestyLogger(logger resty.Logger) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.logger = logger
+		if logger != nil {
+			client.resty.SetLogger(logger)
+		}
+	}
+}
+
+// WithClock sets the function used to get the current time, instead of time.Now.
+// It's used to time requests and to sign them, allowing deterministic tests.
+func WithClock(fn func() time.Time) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.clock = fn
+	}
+}
+
+// WithBaseContext sets the context used by requests that don't call SetContext,
+// such as a service shutdown context, so cancelling it aborts in-flight requests
+// and stops the retry and circuit breaker runners.
+func WithBaseContext(ctx context.Context) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.baseContext = ctx
+	}
+}
+
+// WithCookieJar encapsulates the resty library to set the cookie jar used to store
+// cookies received in responses and send them on subsequent requests to the same host.
+// A nil jar disables cookie persistence.
+//
+// More information about this feature: https://github.com/go-resty/resty/tree/v1.x
+func WithCookieJar(jar http.CookieJar) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.SetCookieJar(jar)
+	}
+}
+
+// WithInMemoryCookieJar sets a new in-memory cookie jar to the client.
+//
+// More information about the jar: net/http/cookiejar.
+func WithInMemoryCookieJar() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		jar, _ := cookiejar.New(nil)
+		client.resty.SetCookieJar(jar)
+	}
+}
+
 // WithHostURL encapsulates the resty library to set a host url.
 //
 // More information about this feature: https://github.com/go-resty/resty/tree/v1.x
@@ -233,19 +868,50 @@ func WithHostURL(baseURL string) func(*HTTPClient) {
 //	    MetricsBucketDuration              time.Duration
 //
 // More information about circuitbreaker config: circuitbreaker.Config
+// State changes can be observed with WithCircuitBreakerStateChange.
 func WithCircuitBreaker(config circuitbreaker.Config) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.chainContextCallback(circuitBreakerCallback(client, config))
+	}
+}
+
+func circuitBreakerCallback(client *HTTPClient, config circuitbreaker.Config) contextCallback {
 	runner := circuitbreaker.New(config)
-	circuitBreakerCallback := func(fn func() (*Response, error)) (*Response, error) {
+	runner = metrics.NewMiddleware("circuitbreaker", newCircuitBreakerRecorder(client))(runner)
+	return func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
 		var resp *Response
-		err := runner.Run(context.Background(), func(ctx context.Context) error {
+		err := runner.Run(ctx, func(ctx context.Context) error {
 			var err error
-			resp, err = fn()
+			resp, err = fn(ctx)
 			return err
 		})
 		return resp, err
 	}
+}
+
+// WithTimeoutResilience cancels the request context when the execution, including
+// the callbacks chained before it, takes longer than d, and returns ErrTimeout.
+// It follows https://github.com/slok/goresilience/tree/master/timeout, but runs
+// the request synchronously so nothing is left running after the timeout.
+//
+// Callbacks wrap the ones chained before them, so use this option before
+// WithCircuitBreaker to count timeouts as circuit breaker failures.
+func WithTimeoutResilience(d time.Duration) func(*HTTPClient) {
 	return func(client *HTTPClient) {
-		client.chainCallback(circuitBreakerCallback)
+		client.chainContextCallback(timeoutCallback(d))
+	}
+}
+
+func timeoutCallback(d time.Duration) contextCallback {
+	return func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+		timeoutCtx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+
+		resp, err := fn(timeoutCtx)
+		if err != nil && ctx.Err() == nil && timeoutCtx.Err() == context.DeadlineExceeded {
+			return resp, ErrTimeout
+		}
+		return resp, err
 	}
 }
 
@@ -258,10 +924,9 @@ func WithExponentialBackoff(retries int, waitTime time.Duration) func(*HTTPClien
 }
 
 // WithBackoff sets a retry strategy based on its configuration.
-// This functionality relies on:
-//
-//	https://github.com/slok/goresilience/tree/master/circuitbreaker
-//	https://github.com/go-resty/resty/tree/v1.x
+// The waits follow https://github.com/slok/goresilience/tree/master/retry, but
+// stop immediately when the request context is done.
+// This functionality also relies on https://github.com/go-resty/resty/tree/v1.x retries.
 //
 // Parameters:
 //
@@ -269,24 +934,143 @@ func WithExponentialBackoff(retries int, waitTime time.Duration) func(*HTTPClien
 //	waitTime: is the amount of time to wait for a new retry.
 //	exponential: this field is used to specify which kind of backoff is used.
 func WithBackoff(retries int, waitTime time.Duration, exponential bool) func(*HTTPClient) {
-	r := retry.New(retry.Config{
-		WaitBase:       waitTime,
-		DisableBackoff: !exponential,
-		Times:          retries,
-	})
-	backoffCallback := func(fn func() (*Response, error)) (*Response, error) {
+	return func(client *HTTPClient) {
+		client.resty.SetRetryCount(retries)
+		client.chainContextCallback(backoffCallback(retries, waitTime, exponential))
+	}
+}
+
+// backoffCallback retries like goresilience/retry, with its defaults of 3 retries
+// and a 20ms wait, but stops waiting when the context is done.
+func backoffCallback(retries int, waitTime time.Duration, exponential bool) contextCallback {
+	if retries <= 0 {
+		retries = 3
+	}
+	if waitTime <= 0 {
+		waitTime = 20 * time.Millisecond
+	}
+
+	return func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
 		var resp *Response
-		err := r.Run(context.Background(), func(ctx context.Context) error {
-			var err error
-			resp, err = fn()
-			return err
-		})
+		var err error
+		for attempt := 0; attempt <= retries; attempt++ {
+			resp, err = fn(ctx)
+			if err == nil || ctx.Err() != nil || retryPrevented(ctx) || attempt == retries {
+				return resp, err
+			}
+
+			if !sleepContext(ctx, backoffWait(waitTime, attempt, exponential)) {
+				return resp, err
+			}
+		}
 
 		return resp, err
 	}
+}
+
+// backoffWait returns how long to wait after the given attempt, applying
+// exponential backoff with full jitter when enabled, as goresilience/retry does.
+func backoffWait(waitTime time.Duration, attempt int, exponential bool) time.Duration {
+	if !exponential {
+		return waitTime
+	}
+
+	wait := time.Duration(float64(waitTime) * math.Exp2(float64(attempt+1))).Round(time.Millisecond)
+	return time.Duration(float64(wait) * rand.Float64())
+}
+
+// sleepContext waits for d, returning false if ctx is done first.
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// WithBackoffBudget sets an exponential retry strategy limited by a total time budget
+// instead of a number of retries. After each error, it waits twice as long as the
+// previous wait, starting from waitBase, and gives up returning the last error when
+// the next attempt would start after maxElapsed.
+//
+// It stops immediately when the request context is done.
+func WithBackoffBudget(waitBase time.Duration, maxElapsed time.Duration) func(*HTTPClient) {
 	return func(client *HTTPClient) {
-		client.resty.SetRetryCount(retries)
-		client.chainCallback(backoffCallback)
+		client.chainContextCallback(func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+			start := client.clock()
+			wait := waitBase
+			for {
+				resp, err := fn(ctx)
+				if err == nil || ctx.Err() != nil || retryPrevented(ctx) || client.clock().Sub(start)+wait > maxElapsed {
+					return resp, err
+				}
+
+				if !sleepContext(ctx, wait) {
+					return resp, err
+				}
+				wait *= 2
+			}
+		})
+	}
+}
+
+// WithClientName sets the client name used to prefix its metric keys, as in
+// "<name>.<alias>.total", so the metrics of several clients in the same process
+// don't collide.
+func WithClientName(name string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.name = name
+	}
+}
+
+// WithMetricsKeyFormatter sets the function that builds the metric keys of the
+// requests, e.g. to keep the dots of the hostname for metrics backends that use
+// them as hierarchy separators. It receives the request method, its absolute URL,
+// and its alias, which is empty unless set with Request.SetAlias or
+// WithMetricsAlias. The returned key is used as is, without the client name
+// prefix.
+//
+// By default, the alias or, when empty, the method, hostname and path are used,
+// with the dots replaced with dashes.
+func WithMetricsKeyFormatter(fn func(method, url, alias string) string) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.metricsKeyFormatter = fn
+	}
+}
+
+// WithLatencyBuckets sets the upper bounds, in seconds, of latency buckets for
+// metrics backends without native histograms. Besides the response time series,
+// each request increments the counter of the first bucket whose bound is greater
+// than or equal to its response time, as in "<key>.latency_bucket.0.25", or the
+// "<key>.latency_bucket.+Inf" counter when it's greater than every bound.
+func WithLatencyBuckets(bounds []float64) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.latencyBuckets = append([]float64(nil), bounds...)
+		sort.Float64s(client.latencyBuckets)
+	}
+}
+
+// WithSynchronousMetrics makes the metrics be registered inline, before Execute
+// returns, instead of in a separate goroutine, avoiding its scheduling and
+// allocation costs. The Metrics implementation must then be fast and non-blocking,
+// as it adds to the latency of every request.
+func WithSynchronousMetrics() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.syncMetrics = true
+	}
+}
+
+// WithMetricsSampling registers the response time series of only a fraction of the
+// requests, given by rate from 0 to 1, to reduce the cost of the metrics of high
+// throughput clients. The counters, such as the total and status ones, still
+// count every request.
+func WithMetricsSampling(rate float64) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.metricsSampleRate = rate
 	}
 }
 
@@ -329,7 +1113,7 @@ func WithRetries(retries int, waitTime time.Duration, maxWaitTime time.Duration)
 }
 
 // WithRetryConditions sets conditions to retry strategy. The conditions will be
-// checked for a new retry.
+// checked for a new retry. Dry runs aren't retried.
 // This functionality relies on:
 //
 //	https://github.com/go-resty/resty/tree/v1.x
@@ -338,18 +1122,197 @@ func WithRetries(retries int, waitTime time.Duration, maxWaitTime time.Duration)
 func WithRetryConditions(conditions ...resty.RetryConditionFunc) func(*HTTPClient) {
 	return func(client *HTTPClient) {
 		for _, condition := range conditions {
-			client.resty.AddRetryCondition(condition)
+			condition := condition
+			client.resty.AddRetryCondition(func(resp *resty.Response, err error) bool {
+				return !errors.Is(err, errDryRun) && condition(resp, err)
+			})
+		}
+	}
+}
+
+// WithRetryOnConnectionErrors retries up to maxRetries times the requests that fail
+// with transient connection errors, such as an idle keep-alive connection closed by
+// the server: io.EOF, syscall.ECONNRESET and net.ErrClosed. Other errors are not
+// retried, unless allowed by conditions added with WithRetryConditions.
+//
+// POST and PATCH requests are only retried when they have an Idempotency-Key header.
+func WithRetryOnConnectionErrors(maxRetries int) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.SetRetryCount(maxRetries)
+		client.resty.AddRetryCondition(func(resp *resty.Response, err error) bool {
+			if !isConnectionError(err) || resp == nil || resp.Request == nil {
+				return false
+			}
+			return isIdempotent(resp.Request.Method) || resp.Request.Header.Get("Idempotency-Key") != ""
+		})
+	}
+}
+
+// WithIdempotentRetriesOnly prevents the retries of POST and PATCH requests, which
+// may duplicate writes when the server processed the first attempt, unless they
+// have an Idempotency-Key header set with SetIdempotencyKey. It applies to every
+// retry strategy: resty retries, WithBackoff, WithBackoffBudget and WithResilience.
+// The result of the first attempt is returned instead.
+func WithIdempotentRetriesOnly() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		if client.idempotentRetries {
+			return
 		}
+		client.idempotentRetries = true
+		client.resty.AddRetryHook(saveRetriedResult)
+		client.resty.OnBeforeRequest(preventRetry)
+	}
+}
+
+// errRetryPrevented stops the resty retries of a request whose retries are prevented.
+var errRetryPrevented = errors.New("retry prevented for non-idempotent request")
+
+// retryGuard keeps the result of the first attempt of a request whose retries
+// are prevented, to be returned instead of errRetryPrevented.
+type retryGuard struct {
+	response *resty.Response
+	err      error
+}
+
+// saveRetriedResult keeps the result of the attempt about to be retried.
+func saveRetriedResult(resp *resty.Response, err error) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	if guard := requestRetryGuard(resp.Request.Context()); guard != nil && guard.response == nil {
+		guard.response, guard.err = resp, err
+	}
+}
+
+// preventRetry stops the resty retries of the requests with a retryGuard.
+func preventRetry(_ *resty.Client, req *resty.Request) error {
+	if req.Attempt > 1 && retryPrevented(req.Context()) {
+		return errRetryPrevented
 	}
+	return nil
+}
+
+func isConnectionError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, net.ErrClosed)
+}
+
+func isIdempotent(method string) bool {
+	return method != http.MethodPost && method != http.MethodPatch
 }
 
 // WithChainCallback provides a callback functionality that takes as input a Callback type.
+// Callbacks wrap the ones chained before them by earlier options, so the last one
+// runs outermost. See WithResilience for a fixed composition of the built-in ones.
 func WithChainCallback(fn Callback) func(*HTTPClient) {
 	return func(client *HTTPClient) {
 		client.chainCallback(fn)
 	}
 }
 
-func noopCallback(fn func() (*Response, error)) (*Response, error) {
-	return fn()
+// WithErrorOnHTTPError makes requests return a *HTTPError along with the Response
+// for non-2xx statuses, instead of a nil error. The error is produced after the
+// callbacks, so it doesn't trigger retries nor count as a circuit breaker failure.
+func WithErrorOnHTTPError() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.errorOnHTTPError = true
+	}
+}
+
+// WithRequestMiddleware adds a function to inspect or modify every request
+// before it is sent. Middlewares run in registration order, and an error
+// returned by any of them aborts the request without sending it.
+func WithRequestMiddleware(fn func(*Request) error) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.requestMiddlewares = append(client.requestMiddlewares, fn)
+	}
+}
+
+// WithBodyValidator validates the request bodies before sending them, e.g. against
+// a JSON schema. fn receives the Content-Type header and the body as it's sent,
+// with structs and maps encoded as JSON, or as XML for XML content types, and the
+// requests fail with the error it returns without being sent. Bodies set as
+// an io.Reader aren't validated, as that would consume them.
+func WithBodyValidator(fn func(contentType string, body []byte) error) func(*HTTPClient) {
+	return WithRequestMiddleware(func(r *Request) error {
+		if r.restyRequest.Body == nil {
+			return nil
+		}
+
+		contentType, body, ok, err := r.serializedBody()
+		if err != nil {
+			return &bodyError{err}
+		}
+		if !ok {
+			return nil
+		}
+		return fn(contentType, body)
+	})
+}
+
+// WithResponseMiddleware adds a function to inspect or modify every response.
+// Middlewares run in registration order after the response is received, and
+// an error returned by any of them is returned by Request.Execute.
+//
+// The metrics alias of the request is available through
+// Response.Request().MetricsAlias().
+func WithResponseMiddleware(fn func(*Response) error) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.responseMiddlewares = append(client.responseMiddlewares, fn)
+	}
+}
+
+// WithSlowRequestThreshold calls fn with every response whose ResponseTime exceeds
+// d, e.g. to warn operators of degraded upstreams with its URL and duration. When
+// fn is nil, a warning is logged through the client logger instead. It's called
+// for every attempt, before the response middlewares, even if the attempt fails.
+func WithSlowRequestThreshold(d time.Duration, fn func(resp *Response)) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		if fn == nil {
+			fn = func(resp *Response) {
+				logger := client.logger
+				if logger == nil {
+					logger = &LoggerAdapter{Writer: os.Stderr}
+				}
+				logger.Warnf("slow request %s %s: %s, over %s",
+					resp.request.method, resp.request.url, resp.ResponseTime(), d)
+			}
+		}
+		client.slowThreshold = d
+		client.slowRequest = fn
+	}
+}
+
+// WithResponseValidator validates every response received, e.g. against the schema
+// of the API in contract tests or canaries. An error returned by fn is returned by
+// Request.Execute along with the response, and is registered in the error
+// metrics. The request is available through Response.Request() to correlate them.
+func WithResponseValidator(fn func(resp *Response) error) func(*HTTPClient) {
+	return WithResponseMiddleware(fn)
+}
+
+// WithOnBeforeRequest encapsulates the resty library to register a hook on its
+// native middleware chain, which runs on every attempt, including retries, before
+// the request is built. Unlike WithRequestMiddleware, it's useful to integrate
+// libraries that already support resty.
+//
+// More information about this feature: https://github.com/go-resty/resty/tree/v1.x
+func WithOnBeforeRequest(fn resty.RequestMiddleware) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.OnBeforeRequest(fn)
+	}
+}
+
+// WithOnAfterResponse encapsulates the resty library to register a hook on its
+// native middleware chain, which runs on every attempt that receives a response,
+// after its body is read.
+//
+// More information about this feature: https://github.com/go-resty/resty/tree/v1.x
+func WithOnAfterResponse(fn resty.ResponseMiddleware) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.resty.OnAfterResponse(fn)
+	}
+}
+
+func noopCallback(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+	return fn(ctx)
 }
@@ -2,23 +2,99 @@ package httpclient_test
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/globocom/httpclient"
+	"github.com/globocom/httpclient/ntlm"
+	resty "github.com/go-resty/resty/v2"
 	"github.com/slok/goresilience/circuitbreaker"
 	goresilienceErrors "github.com/slok/goresilience/errors"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
 )
 
 func TestHTTPClient(t *testing.T) {
+	t.Run("WithClient", testNewHTTPClientWithClient)
 	t.Run("CircuitBreaker", testCircuitBreaker)
+	t.Run("CircuitBreakerStateChange", testCircuitBreakerStateChange)
+	t.Run("CircuitBreakerMetrics", testCircuitBreakerMetrics)
+	t.Run("TimeoutResilience", testTimeoutResilience)
 	t.Run("Retries", testRetries)
+	t.Run("BackoffExhausted", testBackoffExhausted)
+	t.Run("BackoffBudget", testBackoffBudget)
+	t.Run("RestyRetries", testRestyRetries)
+	t.Run("RetryOnConnectionErrors", testRetryOnConnectionErrors)
+	t.Run("IdempotentRetriesOnly", testIdempotentRetriesOnly)
 	t.Run("Callback", testCallback)
+	t.Run("CookieJar", testCookieJar)
+	t.Run("UserAgentAppend", testUserAgentAppend)
+	t.Run("OAuthTokenSource", testOAuthTokenSource)
+	t.Run("AuthTokenProvider", testAuthTokenProvider)
+	t.Run("AuthScheme", testAuthScheme)
+	t.Run("ProxyFunc", testProxyFunc)
+	t.Run("SOCKS5Proxy", testSOCKS5Proxy)
+	t.Run("DialTimeout", testDialTimeout)
+	t.Run("DialControl", testDialControl)
+	t.Run("ResponseHeaderTimeout", testResponseHeaderTimeout)
+	t.Run("ExpectContinueTimeout", testExpectContinueTimeout)
+	t.Run("KeepAlivesDisabled", testKeepAlivesDisabled)
+	t.Run("MaxConnsPerHost", testMaxConnsPerHost)
+	t.Run("TLSServerName", testTLSServerName)
+	t.Run("TLSVersions", testTLSVersions)
+	t.Run("BaseContext", testBaseContext)
+	t.Run("TimeoutPerHost", testTimeoutPerHost)
+	t.Run("RequestMiddleware", testRequestMiddleware)
+	t.Run("BodyValidator", testBodyValidator)
+	t.Run("ResponseMiddleware", testResponseMiddleware)
+	t.Run("ResponseValidator", testResponseValidator)
+	t.Run("SlowRequestThreshold", testSlowRequestThreshold)
+	t.Run("RestyHooks", testRestyHooks)
+	t.Run("Close", testClose)
+	t.Run("CloseIdleConnections", testCloseIdleConnections)
+}
+
+func testNewHTTPClientWithClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	transport := &http.Transport{}
+	shared := &http.Client{Transport: transport, Jar: jar}
+
+	var logs bytes.Buffer
+	client := httpclient.NewHTTPClientWithClient(shared,
+		httpclient.WithRestyLogger(&httpclient.LoggerAdapter{Writer: &logs}),
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithTimeout(time.Second),
+		httpclient.WithResponseHeaderTimeout(time.Second),
+		httpclient.WithSlowRequestThreshold(0, nil),
+	)
+
+	_, err := client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "slow request GET")
+	assert.Equal(t, time.Second, client.GetClient().Timeout)
+	assert.Zero(t, shared.Timeout)
+	assert.Equal(t, time.Second, client.GetClient().Transport.(*http.Transport).ResponseHeaderTimeout)
+	assert.Zero(t, transport.ResponseHeaderTimeout)
+
+	serverURL, _ := url.Parse(server.URL)
+	assert.Len(t, jar.Cookies(serverURL), 1)
 }
 
 func testCircuitBreaker(t *testing.T) {
@@ -54,6 +130,89 @@ func testCircuitBreaker(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func testCircuitBreakerMetrics(t *testing.T) {
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithMetrics(metrics),
+		httpclient.WithCircuitBreaker(circuitbreaker.Config{
+			ErrorPercentThresholdToOpen: 1,
+			MinimumRequestToOpen:        1,
+			WaitDurationInOpenState:     time.Minute,
+		}),
+	)
+
+	_, err := client.NewRequest().SetAlias("api").Get("")
+	assert.Error(t, err)
+	assert.Eventually(t, func() bool { return metrics.counter("api.total") == 1 }, time.Second, 10*time.Millisecond)
+
+	_, err = client.NewRequest().SetAlias("api").Get("")
+	assert.ErrorIs(t, err, httpclient.ErrCircuitOpen)
+	assert.Eventually(t, func() bool { return metrics.counter("api.total") == 2 }, time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, []map[string]string{{}, {"outcome": "circuit_open"}}, metrics.attributes("api.total"))
+	assert.Equal(t, 1, metrics.counter("api.circuit_open"))
+	assert.Equal(t, 1, metrics.counter("api.errors"))
+}
+
+func testCircuitBreakerStateChange(t *testing.T) {
+	openDuration := 50 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	var transitions []string
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithCircuitBreaker(circuitbreaker.Config{
+			ErrorPercentThresholdToOpen:  1,
+			MinimumRequestToOpen:         1,
+			SuccessfulRequiredOnHalfOpen: 1,
+			WaitDurationInOpenState:      openDuration,
+		}),
+		httpclient.WithCircuitBreakerStateChange(func(from, to string) {
+			transitions = append(transitions, from+"->"+to)
+		}),
+	)
+
+	_, err := client.NewRequest().Get("")
+	assert.Error(t, err)
+
+	_, err = client.NewRequest().Get(server.URL)
+	assert.Equal(t, goresilienceErrors.ErrCircuitOpen, err)
+
+	time.Sleep(2 * openDuration)
+
+	_, err = client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"closed->open", "open->halfopen", "halfopen->closed"}, transitions)
+}
+
+func testTimeoutResilience(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithTimeoutResilience(20*time.Millisecond),
+		httpclient.WithCircuitBreaker(circuitbreaker.Config{
+			ErrorPercentThresholdToOpen: 1,
+			MinimumRequestToOpen:        1,
+			WaitDurationInOpenState:     time.Minute,
+		}),
+	)
+
+	start := time.Now()
+	_, err := client.NewRequest().Get(server.URL)
+	assert.Equal(t, httpclient.ErrTimeout, err)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+	_, err = client.NewRequest().Get(server.URL)
+	assert.Equal(t, httpclient.ErrCircuitOpen, err)
+}
+
 func testRetries(t *testing.T) {
 	expectedTimes := 3
 	waitAmount := 500 * time.Millisecond
@@ -100,6 +259,262 @@ func testRetries(t *testing.T) {
 
 }
 
+func testRestyRetries(t *testing.T) {
+	times := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		times++
+		if times < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithRetries(3, time.Millisecond, time.Millisecond),
+		httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool {
+			return resp.StatusCode() == http.StatusServiceUnavailable
+		}),
+	)
+
+	resp, err := client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, 3, resp.Attempts())
+}
+
+func testRetryOnConnectionErrors(t *testing.T) {
+	var drop int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if atomic.CompareAndSwapInt32(&drop, 1, 0) {
+			conn, _, _ := rw.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		if req.URL.Path == "/unavailable" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithRetryOnConnectionErrors(2),
+	)
+
+	tests := map[string]struct {
+		request          func(*httpclient.Request) (*httpclient.Response, error)
+		drop             bool
+		expectedAttempts int
+	}{
+		"GET": {
+			request:          func(r *httpclient.Request) (*httpclient.Response, error) { return r.Get("/") },
+			drop:             true,
+			expectedAttempts: 2,
+		},
+		"POST": {
+			request: func(r *httpclient.Request) (*httpclient.Response, error) { return r.Post("/") },
+			drop:    true,
+		},
+		"POSTWithIdempotencyKey": {
+			request: func(r *httpclient.Request) (*httpclient.Response, error) {
+				return r.SetHeader("Idempotency-Key", "42").Post("/")
+			},
+			drop:             true,
+			expectedAttempts: 2,
+		},
+		"StatusNotRetried": {
+			request:          func(r *httpclient.Request) (*httpclient.Response, error) { return r.Get("/unavailable") },
+			expectedAttempts: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.drop {
+				atomic.StoreInt32(&drop, 1)
+			}
+			client.GetClient().CloseIdleConnections()
+
+			resp, err := test.request(client.NewRequest())
+
+			if test.expectedAttempts == 0 {
+				assert.ErrorIs(t, err, io.EOF)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.expectedAttempts, resp.Attempts())
+			}
+		})
+	}
+}
+
+func testIdempotentRetriesOnly(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if req.URL.Path == "/drop" {
+			conn, _, _ := rw.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	unavailable := func(resp *resty.Response, err error) bool {
+		return resp != nil && resp.StatusCode() == http.StatusServiceUnavailable
+	}
+	clients := map[string]*httpclient.HTTPClient{
+		"RestyRetries": httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithRetries(2, time.Millisecond, time.Millisecond),
+			httpclient.WithRetryConditions(unavailable),
+			httpclient.WithIdempotentRetriesOnly(),
+		),
+		"Backoff": httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithBackoff(2, time.Millisecond, false),
+			httpclient.WithRetryConditions(unavailable),
+			httpclient.WithIdempotentRetriesOnly(),
+		),
+	}
+
+	for name, client := range clients {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&calls, 0)
+			resp, err := client.NewRequest().Post("/")
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode())
+			assert.Equal(t, 1, resp.Attempts())
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+			atomic.StoreInt32(&calls, 0)
+			_, err = client.NewRequest().Post("/drop")
+
+			assert.ErrorIs(t, err, io.EOF)
+			assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+			atomic.StoreInt32(&calls, 0)
+			resp, err = client.NewRequest().SetIdempotencyKey("42").Post("/")
+
+			assert.NoError(t, err)
+			assert.Equal(t, 3, resp.Attempts())
+			assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+			atomic.StoreInt32(&calls, 0)
+			_, err = client.NewRequest().Get("/")
+
+			assert.NoError(t, err)
+			assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+		})
+	}
+}
+
+func testBackoffExhausted(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	newClient := func(attempts *int, retries int, waitTime time.Duration) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLinearBackoff(retries, waitTime),
+			httpclient.WithRequestMiddleware(func(*httpclient.Request) error {
+				*attempts++
+				return errUnavailable
+			}),
+		)
+	}
+
+	t.Run("NoWaitAfterLastAttempt", func(t *testing.T) {
+		attempts := 0
+		client := newClient(&attempts, 2, 100*time.Millisecond)
+
+		start := time.Now()
+		_, err := client.NewRequest().Get("http://localhost")
+
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 3, attempts)
+		assert.Less(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("Defaults", func(t *testing.T) {
+		attempts := 0
+		client := newClient(&attempts, 0, 0)
+
+		start := time.Now()
+		_, err := client.NewRequest().Get("http://localhost")
+
+		// 3 retries, waiting 20ms before each of them.
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 4, attempts)
+		assert.GreaterOrEqual(t, time.Since(start), 60*time.Millisecond)
+	})
+}
+
+func testBackoffBudget(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	newClient := func(attempts *int, maxElapsed time.Duration, options ...httpclient.Opt) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			append([]httpclient.Opt{
+				httpclient.WithBackoffBudget(20*time.Millisecond, maxElapsed),
+				httpclient.WithRequestMiddleware(func(*httpclient.Request) error {
+					*attempts++
+					return errUnavailable
+				}),
+			}, options...)...,
+		)
+	}
+
+	t.Run("Budget", func(t *testing.T) {
+		attempts := 0
+		client := newClient(&attempts, 200*time.Millisecond)
+
+		start := time.Now()
+		_, err := client.NewRequest().Get("http://localhost")
+
+		// Attempts start at 0ms, 20ms, 60ms and 140ms; the next one would start at 300ms.
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 4, attempts)
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("ContextCancelled", func(t *testing.T) {
+		attempts := 0
+		client := newClient(&attempts, 10*time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.NewRequest().SetContext(ctx).Get("http://localhost")
+
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Clock", func(t *testing.T) {
+		now := time.Now()
+		clock := func() time.Time {
+			now = now.Add(time.Minute)
+			return now
+		}
+
+		attempts := 0
+		client := newClient(&attempts, 10*time.Second, httpclient.WithClock(clock))
+
+		_, err := client.NewRequest().Get("http://localhost")
+
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 1, attempts)
+	})
+}
+
 func testCallback(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(handleFunc))
 	defer server.Close()
@@ -124,3 +539,821 @@ func testCallback(t *testing.T) {
 
 	assert.Equal(t, fmt.Sprint(resp.StatusCode()), b.String())
 }
+
+func testUserAgentAppend(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		userAgent = req.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	appendComponent := httpclient.WithUserAgentAppend("component/1.2")
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithUserAgent("base-ua"),
+		appendComponent,
+	)
+
+	_, err := client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "base-ua component/1.2", userAgent)
+
+	client = httpclient.NewHTTPClient(&httpclient.LoggerAdapter{Writer: io.Discard}, appendComponent)
+
+	_, err = client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, "component/1.2", userAgent)
+}
+
+func testCookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "42"})
+			return
+		}
+		if cookie, err := req.Cookie("session"); err == nil {
+			rw.Write([]byte(cookie.Value))
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithInMemoryCookieJar(),
+	)
+
+	_, err := client.NewRequest().Post("/login")
+	assert.NoError(t, err)
+
+	resp, err := client.NewRequest().Get("/profile")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("42"), resp.Body())
+}
+
+func testOAuthTokenSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	t.Run("Token", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithOAuthTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}), time.Second),
+		)
+
+		resp, err := client.NewRequest().Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer token", string(resp.Body()))
+	})
+
+	t.Run("TokenRetries", func(t *testing.T) {
+		source := &failingTokenSource{failures: 2}
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithOAuthTokenSource(source, time.Second),
+			httpclient.WithOAuthTokenRetries(2, time.Millisecond),
+		)
+
+		resp, err := client.NewRequest().Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Bearer token", string(resp.Body()))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&source.calls))
+	})
+
+	t.Run("TokenRetriesContextDone", func(t *testing.T) {
+		source := &failingTokenSource{failures: 10}
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithOAuthTokenSource(source, time.Second),
+			httpclient.WithOAuthTokenRetries(5, time.Second),
+		)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		_, err := client.NewRequest().SetContext(ctx).Get("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrTokenAcquisition)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&source.calls))
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("TokenAcquisitionError", func(t *testing.T) {
+		metrics := newMetricsRecorder()
+		source := &failingTokenSource{failures: 10}
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithOAuthTokenSource(source, time.Second),
+			httpclient.WithMetrics(metrics),
+		)
+
+		_, err := client.NewRequest().SetAlias("api").Get("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrTokenAcquisition)
+		assert.ErrorIs(t, err, errTokenEndpoint)
+		assert.Eventually(t, func() bool { return metrics.counter("api.oauth_error") == 1 }, time.Second, 10*time.Millisecond)
+		assert.Zero(t, metrics.counter("api.errors"))
+	})
+}
+
+func testAuthTokenProvider(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rw.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	var tokens int32
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithAuthTokenProvider(func(ctx context.Context) (string, error) {
+			if ctx.Value(contextKey("fail")) != nil {
+				return "", errTokenEndpoint
+			}
+			return fmt.Sprintf("token-%d", atomic.AddInt32(&tokens, 1)), nil
+		}),
+	)
+
+	for _, expected := range []string{"Bearer token-1", "Bearer token-2"} {
+		resp, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(resp.Body()))
+	}
+
+	ctx := context.WithValue(context.Background(), contextKey("fail"), true)
+	_, err := client.NewRequest().SetContext(ctx).Get("/")
+
+	assert.ErrorIs(t, err, errTokenEndpoint)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+type contextKey string
+
+var errTokenEndpoint = errors.New("token endpoint unavailable")
+
+// failingTokenSource fails the first failures calls to Token.
+type failingTokenSource struct {
+	failures int32
+	calls    int32
+}
+
+func (s *failingTokenSource) Token() (*oauth2.Token, error) {
+	if atomic.AddInt32(&s.calls, 1) <= s.failures {
+		return nil, errTokenEndpoint
+	}
+	return &oauth2.Token{AccessToken: "token"}, nil
+}
+
+func testProxyFunc(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("proxied " + req.URL.String()))
+	}))
+	defer proxy.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithProxyFunc(func(req *http.Request) (*url.URL, error) {
+			if req.URL.Path == "/proxied" {
+				return proxyURL, nil
+			}
+			return nil, nil
+		}),
+		httpclient.WithDefaultTransport(time.Second),
+	)
+
+	resp, err := client.NewRequest().Get(server.URL + "/proxied")
+	assert.NoError(t, err)
+	assert.Equal(t, "proxied "+server.URL+"/proxied", string(resp.Body()))
+
+	resp, err = client.NewRequest().Get(server.URL + "/direct")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("OK"), resp.Body())
+}
+
+func testSOCKS5Proxy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer proxyListener.Close()
+
+	var proxied int32
+	go serveSOCKS5(proxyListener, &proxied)
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithDefaultTransport(time.Second),
+		httpclient.WithSOCKS5Proxy(proxyListener.Addr().String(), nil),
+	)
+
+	resp, err := client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("OK"), resp.Body())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&proxied))
+}
+
+// serveSOCKS5 is a minimal SOCKS5 server supporting only unauthenticated
+// CONNECT requests to IPv4 addresses.
+func serveSOCKS5(listener net.Listener, proxied *int32) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer conn.Close()
+
+			greeting := make([]byte, 2)
+			io.ReadFull(conn, greeting)
+			io.ReadFull(conn, make([]byte, greeting[1]))
+			conn.Write([]byte{5, 0})
+
+			request := make([]byte, 10)
+			if _, err := io.ReadFull(conn, request); err != nil || request[3] != 1 {
+				return
+			}
+			target := net.JoinHostPort(net.IP(request[4:8]).String(), fmt.Sprint(int(request[8])<<8|int(request[9])))
+			upstream, err := net.Dial("tcp", target)
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+
+			atomic.AddInt32(proxied, 1)
+			conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+			go io.Copy(upstream, conn)
+			io.Copy(conn, upstream)
+		}()
+	}
+}
+
+func testDialTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithDialTimeout(time.Nanosecond, time.Minute),
+		httpclient.WithDefaultTransport(10*time.Second),
+	)
+
+	_, err := client.NewRequest().Get(server.URL)
+
+	var netErr net.Error
+	if assert.ErrorAs(t, err, &netErr) {
+		assert.True(t, netErr.Timeout())
+	}
+}
+
+func testDialControl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	var addresses []string
+	errControl := errors.New("control error")
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithDialControl(func(network, address string, c syscall.RawConn) error {
+			addresses = append(addresses, address)
+			if len(addresses) > 1 {
+				return errControl
+			}
+			return nil
+		}),
+		httpclient.WithKeepAlivesDisabled(),
+	)
+
+	_, err := client.NewRequest().Get(server.URL)
+	assert.NoError(t, err)
+
+	_, err = client.NewRequest().Get(server.URL)
+	assert.ErrorIs(t, err, errControl)
+	assert.Equal(t, []string{server.Listener.Addr().String(), server.Listener.Addr().String()}, addresses)
+}
+
+func testResponseHeaderTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/stalled" {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDefaultTransport(time.Second),
+		httpclient.WithResponseHeaderTimeout(50*time.Millisecond),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+
+	_, err = client.NewRequest().Get("/stalled")
+	assert.ErrorContains(t, err, "timeout awaiting response headers")
+}
+
+func testExpectContinueTimeout(t *testing.T) {
+	var expect string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		expect = req.Header.Get("Expect")
+		body, _ = io.ReadAll(req.Body)
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDefaultTransport(time.Second),
+		httpclient.WithExpectContinueTimeout(time.Second),
+	)
+
+	_, err := client.NewRequest().SetExpectContinue().SetBody([]byte("payload")).Post("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "100-continue", expect)
+	assert.Equal(t, []byte("payload"), body)
+}
+
+func testKeepAlivesDisabled(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(handleFunc))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithKeepAlivesDisabled(),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&conns))
+}
+
+func testMaxConnsPerHost(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMaxConnsPerHost(1),
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.NewRequest().Get("/")
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+}
+
+func testBaseContext(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithBaseContext(ctx),
+		httpclient.WithLinearBackoff(3, time.Second),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, calls)
+
+	cancel()
+
+	start := time.Now()
+	_, err = client.NewRequest().Get("/")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, 1, calls)
+
+	_, err = client.NewRequest().SetContext(context.Background()).Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func testTimeoutPerHost(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithTimeout(time.Second),
+		httpclient.WithTimeoutPerHost(map[string]time.Duration{
+			"127.0.0.1": 20 * time.Millisecond,
+		}),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = client.NewRequest().Get("http://localhost:" + serverURL.Port())
+	assert.NoError(t, err)
+}
+
+func testBodyValidator(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	errInvalid := errors.New("missing name")
+	var contentTypes []string
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithBodyValidator(func(contentType string, body []byte) error {
+			contentTypes = append(contentTypes, contentType)
+			if !bytes.Contains(body, []byte(`"name"`)) {
+				return errInvalid
+			}
+			return nil
+		}),
+	)
+
+	_, err := client.NewRequest().SetBody(map[string]string{"name": "globo"}).Post("/")
+	assert.NoError(t, err)
+
+	resp, err := client.NewRequest().SetHeader("Content-Type", "application/json").SetBody(`{"id": 1}`).Post("/")
+	assert.ErrorIs(t, err, errInvalid)
+	assert.Nil(t, resp)
+
+	_, err = client.NewRequest().Get("/")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, []string{"application/json", "application/json"}, contentTypes)
+}
+
+func testRequestMiddleware(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests++
+		assert.Equal(t, "GET /", req.Header.Get("X-Middleware"))
+	}))
+	defer server.Close()
+
+	errForbidden := errors.New("forbidden")
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithRequestMiddleware(func(req *httpclient.Request) error {
+			if req.URL() == "/forbidden" {
+				return errForbidden
+			}
+			return nil
+		}),
+		httpclient.WithRequestMiddleware(func(req *httpclient.Request) error {
+			req.SetHeader("X-Middleware", req.Method()+" "+req.URL())
+			return nil
+		}),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+
+	resp, err := client.NewRequest().Get("/forbidden")
+	assert.ErrorIs(t, err, errForbidden)
+	assert.Nil(t, resp)
+	assert.Equal(t, 1, requests)
+}
+
+func testResponseMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	errMissingHeader := errors.New("missing header")
+	var calls []string
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithResponseMiddleware(func(resp *httpclient.Response) error {
+			calls = append(calls, "first:"+resp.Request().MetricsAlias())
+			return nil
+		}),
+		httpclient.WithResponseMiddleware(func(resp *httpclient.Response) error {
+			calls = append(calls, "second")
+			if resp.Header().Get("Signature") == "" {
+				return errMissingHeader
+			}
+			return nil
+		}),
+	)
+
+	resp, err := client.NewRequest().SetAlias("example").Get("/")
+
+	assert.ErrorIs(t, err, errMissingHeader)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Equal(t, []string{"first:example", "second"}, calls)
+}
+
+func testResponseValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	errContract := errors.New("contract violation")
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithResponseValidator(func(resp *httpclient.Response) error {
+			if resp.Request().URL() == "/users" && !bytes.Contains(resp.Body(), []byte(`"name"`)) {
+				return fmt.Errorf("%s %s: %w", resp.Request().Method(), resp.Request().URL(), errContract)
+			}
+			return nil
+		}),
+	)
+
+	_, err := client.NewRequest().Get("/ids")
+	assert.NoError(t, err)
+
+	resp, err := client.NewRequest().SetAlias("users").Get("/users")
+	assert.ErrorIs(t, err, errContract)
+	assert.EqualError(t, err, "GET /users: contract violation")
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+	assert.Eventually(t, func() bool { return metrics.counter("users.errors") == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func testClose(t *testing.T) {
+	var closed int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(handleFunc))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMaxResponseBodySize(1024),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&closed))
+
+	assert.NoError(t, client.Close())
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&closed) == 1 }, time.Second, 10*time.Millisecond)
+
+	_, err = client.NewRequest().Get("/")
+	assert.NoError(t, err)
+}
+
+func testCloseIdleConnections(t *testing.T) {
+	var conns int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(handleFunc))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tests := map[string][]httpclient.Opt{
+		"Transport":   nil,
+		"NTLMWrapper": {ntlm.WithNTLMAuth("domain", "user", "password")},
+		"DeadlineWrapper": {
+			httpclient.WithDeadlinePropagation("X-Deadline"),
+			ntlm.WithNTLMAuth("domain", "user", "password"),
+		},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			atomic.StoreInt32(&conns, 0)
+			client := httpclient.NewHTTPClient(
+				&httpclient.LoggerAdapter{Writer: io.Discard},
+				append(options, httpclient.WithHostURL(server.URL))...,
+			)
+
+			for i := 0; i < 2; i++ {
+				_, err := client.NewRequest().Get("/")
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, int32(1), atomic.LoadInt32(&conns))
+
+			client.CloseIdleConnections()
+			_, err := client.NewRequest().Get("/")
+
+			assert.NoError(t, err)
+			assert.Equal(t, int32(2), atomic.LoadInt32(&conns))
+		})
+	}
+}
+
+func testRestyHooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Header.Get("X-Trace-ID")))
+	}))
+	defer server.Close()
+
+	var traced []string
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithOnBeforeRequest(func(c *resty.Client, req *resty.Request) error {
+			req.SetHeader("X-Trace-ID", "trace-1")
+			return nil
+		}),
+		httpclient.WithOnAfterResponse(func(c *resty.Client, resp *resty.Response) error {
+			traced = append(traced, resp.String())
+			return nil
+		}),
+	)
+
+	resp, err := client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "trace-1", string(resp.Body()))
+	assert.Equal(t, []string{"trace-1"}, traced)
+}
+
+func testAuthScheme(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithAuthToken("abc"),
+		httpclient.WithAuthScheme("Token"),
+	)
+
+	resp, err := client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Token abc", string(resp.Body()))
+
+	resp, err = client.NewRequest().SetAuthScheme("JWT").SetAuthToken("def").Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "JWT def", string(resp.Body()))
+}
+
+func testTLSServerName(t *testing.T) {
+	var serverName string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(handleFunc))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	newClient := func(name string) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithTransport(server.Client().Transport.(*http.Transport).Clone()),
+			httpclient.WithTLSServerName(name),
+		)
+	}
+
+	// The test certificate is valid for example.com, but not for example.org.
+	_, err := newClient("example.com").NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "example.com", serverName)
+
+	_, err = newClient("example.org").NewRequest().Get("/")
+
+	assert.ErrorContains(t, err, "example.org")
+}
+
+func testTLSVersions(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		fmt.Fprintf(rw, "%#04x", req.TLS.Version)
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	newClient := func(min, max uint16) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithTransport(server.Client().Transport.(*http.Transport).Clone()),
+			httpclient.WithTLSVersions(min, max),
+		)
+	}
+
+	resp, err := newClient(tls.VersionTLS12, 0).NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "0x0303", string(resp.Body()))
+
+	_, err = newClient(tls.VersionTLS13, tls.VersionTLS13).NewRequest().Get("/")
+
+	assert.ErrorContains(t, err, "protocol version")
+
+	assert.PanicsWithValue(t, "httpclient: min TLS version 0x0304 is greater than max 0x0303", func() {
+		httpclient.WithTLSVersions(tls.VersionTLS13, tls.VersionTLS12)
+	})
+}
+
+func testSlowRequestThreshold(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	var slow []string
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithSlowRequestThreshold(25*time.Millisecond, func(resp *httpclient.Response) {
+			slow = append(slow, resp.Request().URL())
+		}),
+	)
+
+	for _, url := range []string{"/fast", "/slow"} {
+		_, err := client.NewRequest().Get(url)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{"/slow"}, slow)
+
+	var buf bytes.Buffer
+	client = httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: &buf},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithSlowRequestThreshold(25*time.Millisecond, nil),
+	)
+
+	_, err := client.NewRequest().Get("/slow")
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `^WARN: slow request GET /slow: \d+(\.\d+)?ms, over 25ms\n$`, buf.String())
+}
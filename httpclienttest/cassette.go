@@ -0,0 +1,234 @@
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/globocom/httpclient"
+)
+
+// ErrNoRecording is returned in ModeReplay for the requests without a matching
+// recording.
+var ErrNoRecording = errors.New("httpclienttest: no recording for request")
+
+// RecordMode is the mode of a cassette.
+type RecordMode int
+
+const (
+	// ModeReplay serves the requests with the recordings of the cassette, without
+	// any network access.
+	ModeReplay RecordMode = iota
+	// ModeRecord sends the requests and records them to the cassette, replacing
+	// its previous recordings.
+	ModeRecord
+)
+
+// Interaction is a request and its response recorded on a cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request recorded on a cassette.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response recorded on a cassette.
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// Matcher reports whether a request, with its body, matches a recorded request.
+type Matcher func(req *http.Request, body []byte, recorded RecordedRequest) bool
+
+var (
+	// MatchMethod matches requests with the same method.
+	MatchMethod Matcher = func(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+		return req.Method == recorded.Method
+	}
+	// MatchURL matches requests with the same URL, query included.
+	MatchURL Matcher = func(req *http.Request, _ []byte, recorded RecordedRequest) bool {
+		return req.URL.String() == recorded.URL
+	}
+	// MatchBody matches requests with the same body.
+	MatchBody Matcher = func(_ *http.Request, body []byte, recorded RecordedRequest) bool {
+		return string(body) == recorded.Body
+	}
+)
+
+// WithCassette records the requests of the client and their responses to the JSON
+// file at path, in ModeRecord, and serves them from it in ModeReplay, making
+// integration tests deterministic. Recordings are matched with all the given
+// matchers, MatchMethod and MatchURL by default, and each one is served once,
+// in the recorded order.
+//
+// The request headers are recorded as sent, so avoid recording credentials to
+// cassettes that are committed.
+func WithCassette(path string, mode RecordMode, matchers ...Matcher) func(*httpclient.HTTPClient) {
+	if len(matchers) == 0 {
+		matchers = []Matcher{MatchMethod, MatchURL}
+	}
+	return httpclient.WithTransportWrapper(func(transport http.RoundTripper) http.RoundTripper {
+		return &cassetteTransport{
+			transport: transport,
+			path:      path,
+			mode:      mode,
+			matchers:  matchers,
+		}
+	})
+}
+
+type cassetteTransport struct {
+	transport http.RoundTripper
+	path      string
+	mode      RecordMode
+	matchers  []Matcher
+
+	mu           sync.Mutex
+	loaded       bool
+	interactions []Interaction
+	replayed     []bool
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.mode == ModeRecord {
+		return t.record(req, body)
+	}
+	return t.replay(req, body)
+}
+
+func (t *cassetteTransport) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.interactions = append(t.interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header,
+			Body:   string(body),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header,
+			Body:       string(respBody),
+		},
+	})
+	if err := t.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *cassetteTransport) replay(req *http.Request, body []byte) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.loaded {
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, interaction := range t.interactions {
+		if t.replayed[i] || !t.matches(req, body, interaction.Request) {
+			continue
+		}
+		t.replayed[i] = true
+
+		recorded := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+			StatusCode:    recorded.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        recorded.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(recorded.Body))),
+			ContentLength: int64(len(recorded.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoRecording, req.Method, req.URL)
+}
+
+func (t *cassetteTransport) matches(req *http.Request, body []byte, recorded RecordedRequest) bool {
+	for _, match := range t.matchers {
+		if !match(req, body, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *cassetteTransport) load() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return fmt.Errorf("httpclienttest: reading cassette: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.interactions); err != nil {
+		return fmt.Errorf("httpclienttest: decoding cassette: %w", err)
+	}
+	t.replayed = make([]bool, len(t.interactions))
+	t.loaded = true
+	return nil
+}
+
+func (t *cassetteTransport) save() error {
+	data, err := json.MarshalIndent(t.interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("httpclienttest: writing cassette: %w", err)
+	}
+	if err := os.WriteFile(t.path, data, 0o644); err != nil {
+		return fmt.Errorf("httpclienttest: writing cassette: %w", err)
+	}
+	return nil
+}
+
+// readRequestBody reads the body of req, leaving it readable again.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,76 @@
+package httpclienttest_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/globocom/httpclient/httpclienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithCassette(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		rw.Header().Set("X-Path", req.URL.Path)
+		rw.WriteHeader(http.StatusAccepted)
+		rw.Write([]byte("echo " + string(body)))
+	}))
+	hostURL := server.URL
+	path := filepath.Join(t.TempDir(), "cassettes", "echo.json")
+
+	recorder := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(hostURL),
+		httpclienttest.WithCassette(path, httpclienttest.ModeRecord),
+	)
+	for _, body := range []string{"a", "b"} {
+		resp, err := recorder.NewRequest().SetBody(body).Post("/echo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "echo "+body, string(resp.Body()))
+	}
+	server.Close()
+
+	t.Run("Replay", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(hostURL),
+			httpclienttest.WithCassette(path, httpclienttest.ModeReplay),
+		)
+
+		for _, body := range []string{"a", "b"} {
+			resp, err := client.NewRequest().SetBody("ignored").Post("/echo")
+
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusAccepted, resp.StatusCode())
+			assert.Equal(t, "/echo", resp.Header().Get("X-Path"))
+			assert.Equal(t, "echo "+body, string(resp.Body()))
+		}
+
+		_, err := client.NewRequest().Post("/echo")
+
+		assert.ErrorIs(t, err, httpclienttest.ErrNoRecording)
+	})
+
+	t.Run("MatchBody", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(hostURL),
+			httpclienttest.WithCassette(path, httpclienttest.ModeReplay,
+				httpclienttest.MatchMethod, httpclienttest.MatchURL, httpclienttest.MatchBody),
+		)
+
+		resp, err := client.NewRequest().SetBody("b").Post("/echo")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "echo b", string(resp.Body()))
+
+		_, err = client.NewRequest().SetBody("c").Post("/echo")
+
+		assert.ErrorIs(t, err, httpclienttest.ErrNoRecording)
+	})
+}
@@ -0,0 +1,74 @@
+// Package httpclienttest provides utilities to test code that uses httpclient
+// without real servers.
+package httpclienttest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/globocom/httpclient"
+)
+
+// ErrUnmatchedRequest is returned for the requests without a MockResponse.
+var ErrUnmatchedRequest = errors.New("httpclienttest: no mock response for request")
+
+// MockResponse is a canned response returned by the transport installed with
+// WithMockResponses.
+type MockResponse struct {
+	// StatusCode is the response status code, 200 when zero.
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Err, when set, is returned by the transport instead of a response.
+	Err error
+}
+
+// WithMockResponses replaces the transport of the client with one that returns
+// the responses keyed by the method and path of the requests, as in "GET /users",
+// without any network access. Requests without a response fail with
+// ErrUnmatchedRequest. The other transport wrappers still apply around it.
+func WithMockResponses(responses map[string]*MockResponse) func(*httpclient.HTTPClient) {
+	return httpclient.WithTransportWrapper(func(http.RoundTripper) http.RoundTripper {
+		return mockTransport(responses)
+	})
+}
+
+type mockTransport map[string]*MockResponse
+
+func (t mockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	key := req.Method + " " + req.URL.Path
+	mock, ok := t[key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnmatchedRequest, key)
+	}
+	if mock.Err != nil {
+		return nil, mock.Err
+	}
+
+	status := mock.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := mock.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(mock.Body)),
+		ContentLength: int64(len(mock.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package httpclienttest_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/globocom/httpclient/httpclienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMockResponses(t *testing.T) {
+	errUpstream := errors.New("upstream")
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL("http://example.invalid"),
+		httpclienttest.WithMockResponses(map[string]*httpclienttest.MockResponse{
+			"GET /users": {
+				Header: http.Header{"Content-Type": {"application/json"}},
+				Body:   []byte(`[{"id":1}]`),
+			},
+			"POST /users":  {StatusCode: http.StatusCreated},
+			"DELETE /user": {Err: errUpstream},
+		}),
+	)
+
+	t.Run("Matched", func(t *testing.T) {
+		resp, err := client.NewRequest().Get("/users")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+		assert.Equal(t, `[{"id":1}]`, string(resp.Body()))
+
+		resp, err = client.NewRequest().SetBody("body").Post("/users")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := client.NewRequest().Delete("/user")
+
+		assert.ErrorIs(t, err, errUpstream)
+	})
+
+	t.Run("Unmatched", func(t *testing.T) {
+		_, err := client.NewRequest().Put("/users")
+
+		assert.ErrorIs(t, err, httpclienttest.ErrUnmatchedRequest)
+		assert.ErrorContains(t, err, "PUT /users")
+	})
+}
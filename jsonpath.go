@@ -0,0 +1,117 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrJSONPathNotFound is returned by Response.JSONPath when a key or index of the
+// path isn't present in the body.
+var ErrJSONPathNotFound = errors.New("json path not found")
+
+// JSONPath returns the value at path in the JSON body, decoded as with
+// json.Unmarshal into an interface{}. The path is made of dotted keys and bracket
+// indexes, as in "data.items[0].id"; an empty path returns the whole body. A key
+// or index that isn't present returns ErrJSONPathNotFound, and one used on a value
+// of the wrong type, such as an index on an object, returns an error describing it.
+func (r Response) JSONPath(path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := r.JSON(&value); err != nil {
+		return nil, err
+	}
+
+	for i, step := range steps {
+		at := path[:step.offset]
+		if i == 0 {
+			at = "the body"
+		}
+
+		if step.isIndex {
+			array, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("json path %q: %s is %s, not an array", path, at, jsonTypeName(value))
+			}
+			if step.index >= len(array) {
+				return nil, fmt.Errorf("%w: %q: index %d out of range of %s", ErrJSONPathNotFound, path, step.index, at)
+			}
+			value = array[step.index]
+			continue
+		}
+
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("json path %q: %s is %s, not an object", path, at, jsonTypeName(value))
+		}
+		if value, ok = object[step.key]; !ok {
+			return nil, fmt.Errorf("%w: %q: key %q missing from %s", ErrJSONPathNotFound, path, step.key, at)
+		}
+	}
+	return value, nil
+}
+
+// jsonPathStep is a key or index of a JSON path, starting at offset.
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+	offset  int
+}
+
+// parseJSONPath splits path into its keys and indexes.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	var steps []jsonPathStep
+	for i := 0; i < len(path); {
+		start := i
+		if path[i] == '[' {
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("json path %q: unclosed bracket", path)
+			}
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("json path %q: invalid index %q", path, path[i+1:i+end])
+			}
+			steps = append(steps, jsonPathStep{index: index, isIndex: true, offset: start})
+			i += end + 1
+			continue
+		}
+
+		if path[i] == '.' && i > 0 {
+			i++
+		}
+		end := strings.IndexAny(path[i:], ".[")
+		if end < 0 {
+			end = len(path) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("json path %q: empty key", path)
+		}
+		steps = append(steps, jsonPathStep{key: path[i : i+end], offset: start})
+		i += end
+	}
+	return steps, nil
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	default:
+		return "a number"
+	}
+}
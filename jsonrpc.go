@@ -0,0 +1,67 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+type (
+	// JSONRPCError is the error member of a JSON-RPC 2.0 response, returned by
+	// Response.JSONRPCResult when the call fails.
+	JSONRPCError struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data,omitempty"`
+	}
+
+	jsonRPCRequest struct {
+		JSONRPC string      `json:"jsonrpc"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+		ID      uint64      `json:"id"`
+	}
+
+	jsonRPCResponse struct {
+		Result json.RawMessage `json:"result"`
+		Error  *JSONRPCError   `json:"error"`
+	}
+)
+
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// jsonRPCID is the id of the last JSON-RPC call.
+var jsonRPCID uint64
+
+// JSONRPC sets the request body as a JSON-RPC 2.0 call of method with params, with
+// an auto-incremented id, and marks the request to be sent with the POST method
+// using Send. params is omitted when nil.
+func (r *Request) JSONRPC(method string, params interface{}) *Request {
+	r.restyRequest.Method = http.MethodPost
+	r.restyRequest.SetHeader("Content-Type", "application/json")
+	return r.SetBody(jsonRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+		ID:      atomic.AddUint64(&jsonRPCID, 1),
+	})
+}
+
+// JSONRPCResult decodes the result member of a JSON-RPC 2.0 response body into v.
+// When the response has an error member, it's returned as a *JSONRPCError.
+func (r Response) JSONRPCResult(v interface{}) error {
+	var body jsonRPCResponse
+	if err := json.Unmarshal(r.body, &body); err != nil {
+		return err
+	}
+	if body.Error != nil {
+		return body.Error
+	}
+	if len(body.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body.Result, v)
+}
@@ -0,0 +1,73 @@
+package httpclient_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRPC(t *testing.T) {
+	var received []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+
+		var call map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&call)
+		received = append(received, call)
+
+		rw.Header().Set("Content-Type", "application/json")
+		if call["method"] == "subtract" {
+			rw.Write([]byte(`{"jsonrpc":"2.0","result":19,"id":1}`))
+			return
+		}
+		rw.Write([]byte(`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":2}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Result", func(t *testing.T) {
+		resp, err := client.NewRequest().JSONRPC("subtract", []int{42, 23}).Send("/rpc")
+
+		var result int
+		if assert.NoError(t, err) {
+			assert.NoError(t, resp.JSONRPCResult(&result))
+			assert.Equal(t, 19, result)
+			assert.Equal(t, "2.0", received[0]["jsonrpc"])
+			assert.Equal(t, []interface{}{42.0, 23.0}, received[0]["params"])
+			assert.NotZero(t, received[0]["id"])
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		resp, err := client.NewRequest().JSONRPC("unknown", nil).Send("/rpc")
+
+		var rpcErr *httpclient.JSONRPCError
+		if assert.NoError(t, err) {
+			err = resp.JSONRPCResult(nil)
+			assert.ErrorAs(t, err, &rpcErr)
+			assert.Equal(t, -32601, rpcErr.Code)
+			assert.EqualError(t, err, "json-rpc error -32601: Method not found")
+			assert.NotContains(t, received[1], "params")
+			assert.Greater(t, received[1]["id"], received[0]["id"])
+		}
+	})
+	t.Run("PreviousBodyError", func(t *testing.T) {
+		resp, err := client.NewRequest().SetBodyJSON(func() {}).JSONRPC("subtract", []int{42, 23}).Send("/rpc")
+
+		var result int
+		if assert.NoError(t, err) {
+			assert.NoError(t, resp.JSONRPCResult(&result))
+			assert.Equal(t, 19, result)
+		}
+	})
+}
@@ -0,0 +1,198 @@
+package httpclient
+
+import (
+	"math/rand"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	resty "github.com/go-resty/resty/v2"
+)
+
+// LBStrategy selects the base URL used by each request of a load balanced client.
+type LBStrategy int
+
+const (
+	// RoundRobin uses the base URLs in turn.
+	RoundRobin LBStrategy = iota
+	// Random uses a random base URL.
+	Random
+)
+
+// WithLoadBalancer spreads the requests across the replicas at urls, picking the
+// base URL of each request with strategy when it's executed. Invalid URLs are
+// ignored. The host of the replica that answered, including its port, is used in
+// the metric keys and returned by Response.Request().HostURL(), so the distribution
+// across replicas can be observed.
+//
+// Only the retries made by resty, such as the ones of WithRetries and the resty
+// retries of WithBackoff, fail over: each of them is sent to the next replica. The
+// attempts made by callbacks, such as the ones of WithBackoffBudget or
+// WithChainCallback, are sent to the last replica tried by the previous attempt.
+func WithLoadBalancer(urls []string, strategy LBStrategy) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.setLoadBalancer(newLoadBalancer(urls, strategy))
+	}
+}
+
+func (c *HTTPClient) setLoadBalancer(balancer *loadBalancer) {
+	if len(balancer.hosts) == 0 {
+		return
+	}
+	if c.balancer == nil {
+		c.resty.OnBeforeRequest(func(restyClient *resty.Client, req *resty.Request) error {
+			return c.balancer.failover(restyClient, req)
+		})
+	}
+	c.balancer = balancer
+}
+
+type loadBalancer struct {
+	hosts    []*url.URL
+	strategy LBStrategy
+	counter  uint32
+
+	mu      sync.RWMutex
+	healthy []bool
+}
+
+func newLoadBalancer(urls []string, strategy LBStrategy) *loadBalancer {
+	balancer := &loadBalancer{strategy: strategy}
+	for _, rawURL := range urls {
+		if host, err := url.Parse(strings.TrimSuffix(rawURL, "/")); err == nil {
+			balancer.hosts = append(balancer.hosts, host)
+			balancer.healthy = append(balancer.healthy, true)
+		}
+	}
+	return balancer
+}
+
+// pick returns the base URL for a new request among the healthy ones.
+func (b *loadBalancer) pick() *url.URL {
+	candidates := b.available()
+	if b.strategy == Random {
+		return b.hosts[candidates[rand.Intn(len(candidates))]]
+	}
+	return b.hosts[candidates[int(atomic.AddUint32(&b.counter, 1)-1)%len(candidates)]]
+}
+
+// available returns the indexes of the healthy hosts, or of every host when
+// none is healthy.
+func (b *loadBalancer) available() []int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var indexes []int
+	for i, healthy := range b.healthy {
+		if healthy {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == 0 {
+		for i := range b.hosts {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+func (b *loadBalancer) setHealthy(i int, healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.healthy[i] = healthy
+}
+
+func (b *loadBalancer) status() map[string]bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := make(map[string]bool, len(b.hosts))
+	for i, host := range b.hosts {
+		status[host.String()] = b.healthy[i]
+	}
+	return status
+}
+
+// failover moves each retry of a request to the next healthy replica. resty resets
+// the URL of every attempt, so the replica is offset by the attempt number. The
+// metrics key of the attempt is moved to the new replica as well.
+func (b *loadBalancer) failover(_ *resty.Client, req *resty.Request) error {
+	if req.Attempt <= 1 {
+		return nil
+	}
+
+	i, rest, ok := b.match(req.URL)
+	if !ok {
+		return nil
+	}
+	host := b.after(i, req.Attempt-1)
+	req.URL = host.String() + rest
+
+	ctx := req.Context()
+	if key, ok := metricsKeyFromContext(ctx); ok && key != "" {
+		if hostKey := balancedMetricsKey(ctx); hostKey != nil {
+			req.SetContext(withMetricsKey(ctx, hostKey(host)))
+		}
+	}
+	return nil
+}
+
+// match returns the index of the host rawURL is prefixed with, and the rest of it.
+// The longest matching host wins, as one may be a prefix of another.
+func (b *loadBalancer) match(rawURL string) (int, string, bool) {
+	index, rest, ok := 0, "", false
+	for i, host := range b.hosts {
+		prefix := host.String()
+		suffix := strings.TrimPrefix(rawURL, prefix)
+		if suffix == rawURL || (suffix != "" && suffix[0] != '/' && suffix[0] != '?') {
+			continue
+		}
+		if !ok || len(suffix) < len(rest) {
+			index, rest, ok = i, suffix, true
+		}
+	}
+	return index, rest, ok
+}
+
+// after returns the nth available host after the host at index i.
+func (b *loadBalancer) after(i, n int) *url.URL {
+	candidates := b.available()
+	position := sort.SearchInts(candidates, i+1) - 1
+	return b.hosts[candidates[(position+n+len(candidates))%len(candidates)]]
+}
+
+// pickHost picks the replica the request is sent to.
+func (r *Request) pickHost() {
+	if r.balancer != nil {
+		r.hostURL = r.balancer.pick()
+	}
+}
+
+// recordHost sets the host URL and metrics key of the request to the replica
+// sentURL was sent to, which differs from the picked one after a failover.
+func (r *Request) recordHost(sentURL string) {
+	if r.balancer == nil {
+		return
+	}
+	if i, _, ok := r.balancer.match(sentURL); ok && r.balancer.hosts[i] != r.hostURL {
+		r.hostURL = r.balancer.hosts[i]
+		r.metricsAlias = r.metricsKey(r.method, r.url)
+	}
+}
+
+// balancedMetricsKey returns the metrics key of the request for each replica.
+func (r *Request) balancedMetricsKey(method, rawURL string) func(host *url.URL) string {
+	return func(host *url.URL) string {
+		return r.hostMetricsKey(method, rawURL, host)
+	}
+}
+
+// balancedURL returns url prefixed with the base URL picked for the request.
+func (r *Request) balancedURL(url string) string {
+	if r.balancer == nil || strings.Contains(url, "://") {
+		return url
+	}
+	return r.hostURL.String() + "/" + strings.TrimPrefix(url, "/")
+}
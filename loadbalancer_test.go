@@ -0,0 +1,156 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadBalancer(t *testing.T) {
+	var hits [3]int32
+	var urls []string
+	for i := range hits {
+		hit := &hits[i]
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(hit, 1)
+			rw.Write([]byte(req.URL.RequestURI()))
+		}))
+		defer server.Close()
+		urls = append(urls, server.URL)
+	}
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		metrics := newMetricsRecorder()
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLoadBalancer(urls, httpclient.RoundRobin),
+			httpclient.WithMetrics(metrics),
+		)
+
+		for i := 0; i < 6; i++ {
+			resp, err := client.NewRequest().SetQueryParams(map[string]string{"page": "1"}).Get("/users")
+			assert.NoError(t, err)
+			assert.Equal(t, "/users?page=1", string(resp.Body()))
+		}
+
+		for i, rawURL := range urls {
+			assert.Equal(t, int32(2), atomic.LoadInt32(&hits[i]))
+
+			host, _ := url.Parse(rawURL)
+			key := "GET-" + strings.Replace(host.Host, ".", "-", -1) + "/users.total"
+			assert.Eventually(t, func() bool { return metrics.counter(key) == 2 }, time.Second, 10*time.Millisecond)
+		}
+	})
+
+	t.Run("Random", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLoadBalancer(urls, httpclient.Random),
+		)
+
+		for i := 0; i < 10; i++ {
+			resp, err := client.NewRequest().Get("/")
+			assert.NoError(t, err)
+			assert.Contains(t, urls, resp.Request().HostURL().String())
+		}
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		dead := httptest.NewServer(http.NotFoundHandler())
+		dead.Close()
+
+		metrics := newMetricsRecorder()
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLoadBalancer([]string{dead.URL, urls[0]}, httpclient.RoundRobin),
+			httpclient.WithRetries(1, time.Millisecond, 10*time.Millisecond),
+			httpclient.WithMetrics(metrics),
+			httpclient.WithSynchronousMetrics(),
+		)
+
+		resp, err := client.NewRequest().Get("/users")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/users", string(resp.Body()))
+		assert.Equal(t, 2, resp.Attempts())
+		assert.Equal(t, urls[0], resp.Request().HostURL().String())
+
+		live, _ := url.Parse(urls[0])
+		deadHost, _ := url.Parse(dead.URL)
+		assert.Equal(t, 1, metrics.counter("GET-"+strings.Replace(live.Host, ".", "-", -1)+"/users.total"))
+		assert.Equal(t, 0, metrics.counter("GET-"+strings.Replace(deadHost.Host, ".", "-", -1)+"/users.total"))
+	})
+
+	t.Run("FailoverNestedURL", func(t *testing.T) {
+		dead := httptest.NewServer(http.NotFoundHandler())
+		dead.Close()
+
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLoadBalancer([]string{dead.URL, dead.URL + "/v2", urls[0]}, httpclient.RoundRobin),
+			httpclient.WithRetries(1, time.Millisecond, 10*time.Millisecond),
+		)
+		// Moves the round robin to the replica nested under the first one.
+		client.NewRequest().Get("/")
+
+		resp, err := client.NewRequest().Get("/users")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/users", string(resp.Body()))
+		assert.Equal(t, urls[0], resp.Request().HostURL().String())
+	})
+}
+
+func TestHealthCheckedUpstreams(t *testing.T) {
+	var healthy int32
+	var hits [2]int32
+	var urls []string
+	for i := range hits {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/health" {
+				if i == 1 && atomic.LoadInt32(&healthy) == 0 {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+				}
+				return
+			}
+			atomic.AddInt32(&hits[i], 1)
+		}))
+		defer server.Close()
+		urls = append(urls, server.URL)
+	}
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHealthCheckedUpstreams(urls, "health", 20*time.Millisecond),
+	)
+	defer client.Close()
+
+	assert.Eventually(t, func() bool {
+		return !client.UpstreamStatus()[urls[1]]
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, client.UpstreamStatus()[urls[0]])
+
+	for i := 0; i < 4; i++ {
+		_, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(4), atomic.LoadInt32(&hits[0]))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&hits[1]))
+
+	atomic.StoreInt32(&healthy, 1)
+	assert.Eventually(t, func() bool {
+		return client.UpstreamStatus()[urls[1]]
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, client.Close())
+	assert.NoError(t, client.Close())
+}
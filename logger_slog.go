@@ -0,0 +1,46 @@
+//go:build go1.21
+
+package httpclient
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// WithLogger logs every executed request with the given structured logger, using
+// the method, url, status and duration attributes. Responses with a 5xx status are
+// logged at error level, 4xx at warn level and the others at info level. Failed
+// requests, such as the ones with transport errors or rejected by the circuit
+// breaker, are logged at error level with the error attribute, along with the
+// status and duration when there's a response.
+func WithLogger(logger *slog.Logger) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.executeHooks = append(client.executeHooks, func(r *Request, resp *Response, err error) {
+			attrs := []slog.Attr{
+				slog.String("method", r.method),
+				slog.String("url", r.withRawQuery(r.resolveURL(r.url))),
+			}
+			if resp != nil {
+				attrs = append(attrs,
+					slog.Int("status", resp.StatusCode()),
+					slog.Duration("duration", resp.ResponseTime()),
+				)
+			}
+
+			level, msg := slog.LevelInfo, "http request"
+			switch {
+			case err != nil:
+				level, msg = slog.LevelError, "http request failed"
+				attrs = append(attrs, slog.Any("error", err))
+			case resp == nil:
+				// A callback of WithChainCallback may swallow the error and the response.
+			case resp.StatusCode() >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case resp.StatusCode() >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+
+			logger.LogAttrs(r.restyRequest.Context(), level, msg, attrs...)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+//go:build go1.21
+
+package httpclient_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+	)
+
+	logEntry := func() map[string]interface{} {
+		defer buf.Reset()
+		entry := map[string]interface{}{}
+		json.Unmarshal(buf.Bytes(), &entry)
+		return entry
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		_, err := client.NewRequest().Get("/")
+		assert.NoError(t, err)
+
+		entry := logEntry()
+		assert.Equal(t, "INFO", entry["level"])
+		assert.Equal(t, "GET", entry["method"])
+		assert.Equal(t, server.URL+"/", entry["url"])
+		assert.Equal(t, float64(http.StatusOK), entry["status"])
+		assert.Contains(t, entry, "duration")
+	})
+
+	t.Run("ClientError", func(t *testing.T) {
+		_, err := client.NewRequest().Get("/missing")
+		assert.NoError(t, err)
+
+		entry := logEntry()
+		assert.Equal(t, "WARN", entry["level"])
+		assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+	})
+
+	t.Run("TransportError", func(t *testing.T) {
+		_, err := client.NewRequest().Get("http://127.0.0.1:1")
+		assert.Error(t, err)
+
+		entry := logEntry()
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "http://127.0.0.1:1", entry["url"])
+		assert.Contains(t, entry, "error")
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithErrorOnHTTPError(),
+			httpclient.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		)
+
+		_, err := client.NewRequest().Get("/missing")
+		assert.Error(t, err)
+
+		entry := logEntry()
+		assert.Equal(t, "ERROR", entry["level"])
+		assert.Equal(t, "http request failed", entry["msg"])
+		assert.Equal(t, float64(http.StatusNotFound), entry["status"])
+		assert.Contains(t, entry, "error")
+	})
+	t.Run("NoResponse", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithChainCallback(func(fn func() (*httpclient.Response, error)) (*httpclient.Response, error) {
+				return nil, nil
+			}),
+			httpclient.WithLogger(slog.New(slog.NewJSONHandler(&buf, nil))),
+		)
+
+		resp, err := client.NewRequest().Get("/")
+		assert.Nil(t, resp)
+		assert.NoError(t, err)
+
+		entry := logEntry()
+		assert.Equal(t, "INFO", entry["level"])
+		assert.Equal(t, "http request", entry["msg"])
+		assert.NotContains(t, entry, "status")
+	})
+}
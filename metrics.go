@@ -8,3 +8,11 @@ type Metrics interface {
 	// IncrCounterWithAttrs increments the counter value identified by the given name while adding attributes.
 	IncrCounterWithAttrs(name string, attributes map[string]string)
 }
+
+// ExemplarMetrics is optionally implemented by Metrics to attach exemplars to the
+// series values. When implemented, the response times are pushed with the request
+// ID, from the "request.id" context value, as the "request_id" exemplar.
+type ExemplarMetrics interface {
+	// PushToSeriesWithExemplar adds a new value to a histogram identified by the given name, with an exemplar.
+	PushToSeriesWithExemplar(name string, value float64, exemplar map[string]string)
+}
@@ -0,0 +1,63 @@
+package httpclient_test
+
+import (
+	"sync"
+)
+
+type metricsRecorder struct {
+	mu       sync.Mutex
+	counters map[string]int
+	series   map[string][]float64
+	attrs    map[string][]map[string]string
+}
+
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{
+		counters: map[string]int{},
+		series:   map[string][]float64{},
+		attrs:    map[string][]map[string]string{},
+	}
+}
+
+func (m *metricsRecorder) IncrCounter(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+}
+
+func (m *metricsRecorder) PushToSeries(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.series[name] = append(m.series[name], value)
+}
+
+func (m *metricsRecorder) IncrCounterWithAttrs(name string, attributes map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[name]++
+	m.attrs[name] = append(m.attrs[name], attributes)
+}
+
+func (m *metricsRecorder) counter(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+func (m *metricsRecorder) seriesLen(name string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.series[name])
+}
+
+func (m *metricsRecorder) attributes(name string) []map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]string(nil), m.attrs[name]...)
+}
+
+func (m *metricsRecorder) values(name string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.series[name]...)
+}
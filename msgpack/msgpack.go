@@ -0,0 +1,25 @@
+// Package msgpack sends and receives MessagePack bodies with httpclient.
+// It's kept apart so that only its users depend on the MessagePack codec.
+package msgpack
+
+import (
+	"github.com/globocom/httpclient"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ContentType is the Content-Type of the request bodies set with SetBody.
+const ContentType = "application/msgpack"
+
+// SetBody sets the body of req to v encoded with msgpack.Marshal, and the
+// Content-Type header to ContentType. An encoding error is returned by Execute,
+// wrapped with httpclient.ErrInvalidBody, without sending the request.
+func SetBody(req *httpclient.Request, v interface{}) *httpclient.Request {
+	return req.SetBodyFunc(ContentType, func() ([]byte, error) {
+		return msgpack.Marshal(v)
+	})
+}
+
+// Unmarshal decodes the body of resp into v with msgpack.Unmarshal.
+func Unmarshal(resp *httpclient.Response, v interface{}) error {
+	return msgpack.Unmarshal(resp.Body(), v)
+}
@@ -0,0 +1,54 @@
+package msgpack_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/globocom/httpclient/msgpack"
+	"github.com/stretchr/testify/assert"
+	codec "github.com/vmihailenco/msgpack/v5"
+)
+
+type user struct {
+	ID   int    `msgpack:"id"`
+	Name string `msgpack:"name"`
+}
+
+func TestMsgpack(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, msgpack.ContentType, req.Header.Get("Content-Type"))
+
+		var u user
+		assert.NoError(t, codec.NewDecoder(req.Body).Decode(&u))
+		u.ID++
+
+		rw.Header().Set("Content-Type", msgpack.ContentType)
+		codec.NewEncoder(rw).Encode(u)
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Body", func(t *testing.T) {
+		resp, err := msgpack.SetBody(client.NewRequest(), user{ID: 1, Name: "globo"}).Post("/")
+
+		var reply user
+		if assert.NoError(t, err) {
+			assert.NoError(t, msgpack.Unmarshal(resp, &reply))
+			assert.Equal(t, user{ID: 2, Name: "globo"}, reply)
+		}
+	})
+
+	t.Run("EncodingError", func(t *testing.T) {
+		resp, err := msgpack.SetBody(client.NewRequest(), make(chan int)).Post("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrInvalidBody)
+		assert.Nil(t, resp)
+	})
+}
@@ -0,0 +1,387 @@
+// Package ntlm authenticates httpclient requests with NTLM (NTLMv2).
+// It's kept apart so that only its users carry the NTLM implementation.
+package ntlm
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/globocom/httpclient"
+)
+
+const (
+	negotiateUnicode            = 0x00000001
+	requestTarget               = 0x00000004
+	negotiateNTLM               = 0x00000200
+	negotiateAlwaysSign         = 0x00008000
+	negotiateExtendedSession    = 0x00080000
+	negotiateTargetInfo         = 0x00800000
+	negotiate128                = 0x20000000
+	negotiate56                 = 0x80000000
+	avIDTimestamp               = 7
+	challengeMinLength          = 48
+	authenticateHeaderLength    = 64
+	windowsEpochTo1970Intervals = 116444736000000000
+)
+
+var signature = []byte("NTLMSSP\x00")
+
+var errInvalidChallenge = errors.New("invalid NTLM challenge message")
+
+// WithNTLMAuth authenticates the requests with NTLM (NTLMv2), as required by
+// some corporate servers. When a server responds with 401 and a
+// "WWW-Authenticate: NTLM" header, the request is sent again through the
+// negotiate/challenge/authenticate exchange. Plain HTTP proxies responding with 407
+// are authenticated in the same way, with the Proxy-Authorization header.
+//
+// NTLM authenticates connections, not requests, so the exchange must happen on a
+// single connection: while a host runs an exchange, no other request is sent to
+// it until the authenticate message gets a connection, so it reuses the one that
+// received the challenge, the most recently used one. Other requests are sent
+// concurrently, on as many connections as the transport allows. Request bodies are
+// sent again during the exchange, so requests with bodies that can't be replayed,
+// such as the ones streamed with SetBodyReader, get the 401 response.
+func WithNTLMAuth(domain, username, password string) httpclient.Opt {
+	return func(client *httpclient.HTTPClient) {
+		httpclient.WithAuthTransportWrapper(func(transport http.RoundTripper) http.RoundTripper {
+			return &authTransport{
+				RoundTripper: transport,
+				domain:       domain,
+				username:     username,
+				password:     password,
+				clock:        client.Now,
+			}
+		})(client)
+	}
+}
+
+// authTransport runs the NTLM exchange for the requests rejected by the server.
+type authTransport struct {
+	http.RoundTripper
+	domain   string
+	username string
+	password string
+	clock    func() time.Time
+
+	mu    sync.Mutex
+	hosts map[string]*sync.RWMutex
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	lock := t.hostLock(req.URL.Host)
+	lock.RLock()
+	resp, err := t.RoundTripper.RoundTrip(req)
+	lock.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	authenticate, authorization := "WWW-Authenticate", "Authorization"
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		authenticate, authorization = "Proxy-Authenticate", "Proxy-Authorization"
+	} else if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	if _, ok := authenticateChallenge(resp.Header.Values(authenticate)); !ok {
+		return resp, nil
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+	discardBody(resp)
+
+	// The exchange holds the host until the authenticate message gets its
+	// connection, so no other request takes the one that received the challenge.
+	lock.Lock()
+	var unlock sync.Once
+	defer unlock.Do(lock.Unlock)
+
+	negotiate, err := cloneRequestWithBody(req)
+	if err != nil {
+		return nil, err
+	}
+	negotiate.Header.Set(authorization, "NTLM "+base64.StdEncoding.EncodeToString(negotiateMessage()))
+	resp, err = t.RoundTripper.RoundTrip(negotiate)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, ok := authenticateChallenge(resp.Header.Values(authenticate))
+	if !ok || encoded == "" {
+		return resp, nil
+	}
+	discardBody(resp)
+
+	challenge, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidChallenge
+	}
+	message, err := authenticateMessage(challenge, t.domain, t.username, t.password, t.clock(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticated, err := cloneRequestWithBody(req)
+	if err != nil {
+		return nil, err
+	}
+	authenticated = authenticated.WithContext(httptrace.WithClientTrace(authenticated.Context(), &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { unlock.Do(lock.Unlock) },
+	}))
+	authenticated.Header.Set(authorization, "NTLM "+base64.StdEncoding.EncodeToString(message))
+	return t.RoundTripper.RoundTrip(authenticated)
+}
+
+// Unwrap returns the transport the requests are sent through.
+func (t *authTransport) Unwrap() http.RoundTripper {
+	return t.RoundTripper
+}
+
+// hostLock returns the lock of host, held exclusively during its NTLM exchanges.
+func (t *authTransport) hostLock(host string) *sync.RWMutex {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.hosts == nil {
+		t.hosts = map[string]*sync.RWMutex{}
+	}
+	lock, ok := t.hosts[host]
+	if !ok {
+		lock = &sync.RWMutex{}
+		t.hosts[host] = lock
+	}
+	return lock
+}
+
+// authenticateChallenge returns the NTLM challenge of the authenticate header values,
+// which is empty in the response to the first request.
+func authenticateChallenge(values []string) (string, bool) {
+	for _, value := range values {
+		scheme, challenge, _ := strings.Cut(strings.TrimSpace(value), " ")
+		if strings.EqualFold(scheme, "NTLM") {
+			return strings.TrimSpace(challenge), true
+		}
+	}
+	return "", false
+}
+
+// cloneRequestWithBody clones req with a new copy of its body.
+func cloneRequestWithBody(req *http.Request) (*http.Request, error) {
+	clone := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		clone.Body = body
+	}
+	return clone, nil
+}
+
+// discardBody reads and closes the body of resp, so its connection can be reused.
+func discardBody(resp *http.Response) {
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// negotiateMessage returns the NEGOTIATE_MESSAGE starting the exchange.
+func negotiateMessage() []byte {
+	message := make([]byte, 32)
+	copy(message, signature)
+	binary.LittleEndian.PutUint32(message[8:], 1)
+	binary.LittleEndian.PutUint32(message[12:], negotiateUnicode|requestTarget|negotiateNTLM|
+		negotiateAlwaysSign|negotiateExtendedSession|negotiateTargetInfo|negotiate128|negotiate56)
+	return message
+}
+
+// authenticateMessage returns the AUTHENTICATE_MESSAGE with the NTLMv2 response
+// to the CHALLENGE_MESSAGE challenge. A random client challenge is used when
+// clientChallenge is nil.
+func authenticateMessage(challenge []byte, domain, username, password string, now time.Time, clientChallenge []byte) ([]byte, error) {
+	if len(challenge) < challengeMinLength || !bytes.Equal(challenge[:8], signature) ||
+		binary.LittleEndian.Uint32(challenge[8:]) != 2 {
+		return nil, errInvalidChallenge
+	}
+	flags := binary.LittleEndian.Uint32(challenge[20:])
+	serverChallenge := challenge[24:32]
+	targetInfo, ok := securityBuffer(challenge, 40)
+	if !ok {
+		return nil, errInvalidChallenge
+	}
+
+	if clientChallenge == nil {
+		clientChallenge = make([]byte, 8)
+		if _, err := rand.Read(clientChallenge); err != nil {
+			return nil, err
+		}
+	}
+
+	timestamp, hasTimestamp := targetTimestamp(targetInfo)
+	if !hasTimestamp {
+		timestamp = make([]byte, 8)
+		binary.LittleEndian.PutUint64(timestamp, uint64(now.UnixNano()/100+windowsEpochTo1970Intervals))
+	}
+
+	hash := v2Hash(domain, username, password)
+	ntResponse, lmResponse := v2Responses(hash, serverChallenge, clientChallenge, timestamp, targetInfo)
+	if hasTimestamp {
+		lmResponse = make([]byte, 24)
+	}
+
+	encode := func(s string) []byte {
+		if flags&negotiateUnicode != 0 {
+			return utf16LE(s)
+		}
+		return []byte(s)
+	}
+
+	payloads := [][]byte{lmResponse, ntResponse, encode(domain), encode(username), encode(""), nil}
+	message := make([]byte, authenticateHeaderLength)
+	copy(message, signature)
+	binary.LittleEndian.PutUint32(message[8:], 3)
+	for i, payload := range payloads {
+		field := 12 + i*8
+		binary.LittleEndian.PutUint16(message[field:], uint16(len(payload)))
+		binary.LittleEndian.PutUint16(message[field+2:], uint16(len(payload)))
+		binary.LittleEndian.PutUint32(message[field+4:], uint32(len(message)))
+		message = append(message, payload...)
+	}
+	binary.LittleEndian.PutUint32(message[60:], flags)
+	return message, nil
+}
+
+// v2Hash returns the NTOWFv2 hash of the credentials.
+func v2Hash(domain, username, password string) []byte {
+	ntHash := md4Sum(utf16LE(password))
+	return hmacMD5(ntHash[:], utf16LE(strings.ToUpper(username)+domain))
+}
+
+// v2Responses returns the NTLMv2 and LMv2 responses to the server challenge.
+func v2Responses(hash, serverChallenge, clientChallenge, timestamp, targetInfo []byte) ([]byte, []byte) {
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = append(temp, timestamp...)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	proof := hmacMD5(hash, append(append([]byte{}, serverChallenge...), temp...))
+	lmProof := hmacMD5(hash, append(append([]byte{}, serverChallenge...), clientChallenge...))
+	return append(proof, temp...), append(lmProof, clientChallenge...)
+}
+
+// securityBuffer returns the payload referenced by the security buffer at offset.
+func securityBuffer(message []byte, offset int) ([]byte, bool) {
+	length := int(binary.LittleEndian.Uint16(message[offset:]))
+	start := int(binary.LittleEndian.Uint32(message[offset+4:]))
+	if start+length > len(message) {
+		return nil, false
+	}
+	return message[start : start+length], true
+}
+
+// targetTimestamp returns the MsvAvTimestamp pair of the target info, if present.
+func targetTimestamp(targetInfo []byte) ([]byte, bool) {
+	for len(targetInfo) >= 4 {
+		id := binary.LittleEndian.Uint16(targetInfo)
+		length := int(binary.LittleEndian.Uint16(targetInfo[2:]))
+		if id == 0 || len(targetInfo) < 4+length {
+			break
+		}
+		if id == avIDTimestamp && length == 8 {
+			return targetInfo[4:12], true
+		}
+		targetInfo = targetInfo[4+length:]
+	}
+	return nil, false
+}
+
+func hmacMD5(key, data []byte) []byte {
+	mac := hmac.New(md5.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+func utf16LE(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(encoded))
+	for i, r := range encoded {
+		binary.LittleEndian.PutUint16(b[2*i:], r)
+	}
+	return b
+}
+
+// md4Sum returns the MD4 checksum of data (RFC 1320), used by the NTLM hash.
+func md4Sum(data []byte) [16]byte {
+	length := uint64(len(data)) * 8
+	data = append(append([]byte{}, data...), 0x80)
+	for len(data)%64 != 56 {
+		data = append(data, 0)
+	}
+	data = append(data, make([]byte, 8)...)
+	binary.LittleEndian.PutUint64(data[len(data)-8:], length)
+
+	state := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	rounds := []struct {
+		f      func(x, y, z uint32) uint32
+		k      uint32
+		order  [16]int
+		shifts [4]int
+	}{
+		{
+			f:      func(x, y, z uint32) uint32 { return x&y | ^x&z },
+			order:  [16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15},
+			shifts: [4]int{3, 7, 11, 19},
+		},
+		{
+			f:      func(x, y, z uint32) uint32 { return x&y | x&z | y&z },
+			k:      0x5a827999,
+			order:  [16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15},
+			shifts: [4]int{3, 5, 9, 13},
+		},
+		{
+			f:      func(x, y, z uint32) uint32 { return x ^ y ^ z },
+			k:      0x6ed9eba1,
+			order:  [16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15},
+			shifts: [4]int{3, 9, 11, 15},
+		},
+	}
+
+	var x [16]uint32
+	for block := 0; block < len(data); block += 64 {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(data[block+4*i:])
+		}
+
+		a, b, c, d := state[0], state[1], state[2], state[3]
+		for _, round := range rounds {
+			for i, k := range round.order {
+				a = bits.RotateLeft32(a+round.f(b, c, d)+x[k]+round.k, round.shifts[i%4])
+				a, b, c, d = d, a, b, c
+			}
+		}
+		state[0] += a
+		state[1] += b
+		state[2] += c
+		state[3] += d
+	}
+
+	var sum [16]byte
+	for i, v := range state {
+		binary.LittleEndian.PutUint32(sum[4*i:], v)
+	}
+	return sum
+}
@@ -0,0 +1,180 @@
+package ntlm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeHex(s string) []byte {
+	b, _ := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	return b
+}
+
+// testChallenge builds a CHALLENGE_MESSAGE with the given target info.
+func testChallenge(serverChallenge, targetInfo []byte) []byte {
+	message := make([]byte, challengeMinLength)
+	copy(message, signature)
+	binary.LittleEndian.PutUint32(message[8:], 2)
+	binary.LittleEndian.PutUint32(message[20:], negotiateUnicode|negotiateNTLM|negotiateTargetInfo)
+	copy(message[24:], serverChallenge)
+	binary.LittleEndian.PutUint16(message[40:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint16(message[42:], uint16(len(targetInfo)))
+	binary.LittleEndian.PutUint32(message[44:], uint32(len(message)))
+	return append(message, targetInfo...)
+}
+
+// Test vectors from [MS-NLMP] 4.2.4, NTLMv2 authentication.
+var (
+	serverChallenge = decodeHex("0123456789abcdef")
+	clientChallenge = decodeHex("aaaaaaaaaaaaaaaa")
+	targetInfo      = append(append(append(
+		decodeHex("02000c00"), utf16LE("Domain")...),
+		append(decodeHex("01000c00"), utf16LE("Server")...)...),
+		decodeHex("00000000")...)
+)
+
+func TestMD4Sum(t *testing.T) {
+	empty, abc := md4Sum(nil), md4Sum([]byte("abc"))
+
+	assert.Equal(t, "31d6cfe0d16ae931b73c59d7e0c089c0", hex.EncodeToString(empty[:]))
+	assert.Equal(t, "a448017aaf21d8525fc10ae87aa6729d", hex.EncodeToString(abc[:]))
+}
+
+func TestNTHash(t *testing.T) {
+	// [MS-NLMP] 4.2.2.1.2, NTOWFv1.
+	hash := md4Sum(utf16LE("Password"))
+
+	assert.Equal(t, decodeHex("a4f49c406510bdcab6824ee7c30fd852"), hash[:])
+}
+
+func TestV2Hash(t *testing.T) {
+	assert.Equal(t, decodeHex("0c868a403bfd7a93a3001ef22ef02e3f"), v2Hash("Domain", "User", "Password"))
+}
+
+func TestV2Responses(t *testing.T) {
+	hash := v2Hash("Domain", "User", "Password")
+
+	ntResponse, lmResponse := v2Responses(hash, serverChallenge, clientChallenge, make([]byte, 8), targetInfo)
+
+	assert.Equal(t, decodeHex("68cd0ab851e51c96aabc927bebef6a1c"), ntResponse[:16])
+	assert.Equal(t, append(decodeHex("0101000000000000 0000000000000000 aaaaaaaaaaaaaaaa 00000000"),
+		append(targetInfo, 0, 0, 0, 0)...), ntResponse[16:])
+	assert.Equal(t, decodeHex("86c35097ac9cec102554764a57cccc19 aaaaaaaaaaaaaaaa"), lmResponse)
+	// The SessionBaseKey, HMAC_MD5(ResponseKeyNT, NTProofStr).
+	assert.Equal(t, decodeHex("8de40ccadbc14a82f15cb0ad0de95ca3"), hmacMD5(hash, ntResponse[:16]))
+}
+
+func TestAuthenticateMessage(t *testing.T) {
+	t.Run("Message", func(t *testing.T) {
+		challenge := testChallenge(serverChallenge, targetInfo)
+
+		message, err := authenticateMessage(challenge, "Domain", "User", "Password", time.Unix(0, 0), clientChallenge)
+
+		assert.NoError(t, err)
+		assert.Equal(t, signature, message[:8])
+		ntResponse, _ := securityBuffer(message, 20)
+		domain, _ := securityBuffer(message, 28)
+		user, _ := securityBuffer(message, 36)
+		assert.Equal(t, utf16LE("Domain"), domain)
+		assert.Equal(t, utf16LE("User"), user)
+		assert.Equal(t, clientChallenge, ntResponse[32:40])
+	})
+
+	t.Run("InvalidChallenge", func(t *testing.T) {
+		_, err := authenticateMessage([]byte("NTLMSSP"), "Domain", "User", "Password", time.Now(), nil)
+
+		assert.Equal(t, errInvalidChallenge, err)
+	})
+}
+
+func TestNTLMAuth(t *testing.T) {
+	t.Run("Exchange", func(t *testing.T) {
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			body, _ := io.ReadAll(req.Body)
+			bodies = append(bodies, string(body))
+
+			message, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(req.Header.Get("Authorization"), "NTLM "))
+			switch {
+			case len(message) == 0:
+				rw.Header().Set("WWW-Authenticate", "NTLM")
+			case message[8] == 1:
+				challenge := testChallenge(serverChallenge, targetInfo)
+				rw.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+			case message[8] == 3:
+				ntResponse, _ := securityBuffer(message, 20)
+				proof := hmacMD5(v2Hash("Domain", "User", "Password"), append(append([]byte{}, serverChallenge...), ntResponse[16:]...))
+				if bytes.Equal(proof, ntResponse[:16]) {
+					return
+				}
+			}
+			rw.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		client := httpclient.NewHTTPClient(nil, httpclient.WithHostURL(server.URL), WithNTLMAuth("Domain", "User", "Password"))
+
+		resp, err := client.NewRequest().SetBody("payload").Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload", "payload"}, bodies)
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			message, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(req.Header.Get("Authorization"), "NTLM "))
+			switch {
+			case len(message) == 0:
+				rw.Header().Set("WWW-Authenticate", "NTLM")
+			case message[8] == 1:
+				challenge := testChallenge(serverChallenge, targetInfo)
+				rw.Header().Set("WWW-Authenticate", "NTLM "+base64.StdEncoding.EncodeToString(challenge))
+			case message[8] == 3:
+				time.Sleep(200 * time.Millisecond)
+				return
+			}
+			rw.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer server.Close()
+
+		client := httpclient.NewHTTPClient(nil, httpclient.WithHostURL(server.URL), WithNTLMAuth("Domain", "User", "Password"))
+
+		var wg sync.WaitGroup
+		statuses := make(chan int, 4)
+		start := time.Now()
+		for i := 0; i < cap(statuses); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := client.NewRequest().Get("/")
+				if err == nil {
+					statuses <- resp.StatusCode()
+				}
+			}()
+		}
+		wg.Wait()
+		close(statuses)
+
+		// The requests aren't serialized: each exchange only holds the host until
+		// its authenticate message gets a connection.
+		assert.Less(t, time.Since(start), 600*time.Millisecond)
+		assert.Len(t, statuses, 4)
+		for status := range statuses {
+			assert.Equal(t, http.StatusOK, status)
+		}
+		assert.Len(t, client.GetClient().Transport.(*authTransport).hosts, 1)
+	})
+}
@@ -0,0 +1,87 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenAcquisition is returned when the OAuth transports of WithOAUTHTransport
+// and WithOAuthTokenSource fail to get a token, wrapping the token source error.
+// These failures are registered in the "<alias>.oauth_error" metric instead of
+// "<alias>.errors".
+var ErrTokenAcquisition = errors.New("oauth token acquisition failed")
+
+// WithOAuthTokenRetries retries up to retries times, waiting waitTime between the
+// attempts, to get the token of the OAuth transports when the token source fails,
+// separately from the request retries. The waits stop when the request context is
+// done, failing the request with the last token error.
+func WithOAuthTokenRetries(retries int, waitTime time.Duration) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.tokenRetries = retries
+		client.tokenRetryWait = waitTime
+	}
+}
+
+// oauthTransport sets the Authorization header of each request to a token from
+// source, as oauth2.Transport does, getting it with the request context.
+type oauthTransport struct {
+	http.RoundTripper
+	source *tokenSource
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.token(req.Context())
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	token.SetAuthHeader(req)
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// tokenSource retries and tags the errors of an oauth2.TokenSource.
+type tokenSource struct {
+	source oauth2.TokenSource
+	client *HTTPClient
+}
+
+// token gets a token from the source, retrying its failures until ctx is done.
+func (s *tokenSource) token(ctx context.Context) (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	for attempt := 0; err != nil && attempt < s.client.tokenRetries; attempt++ {
+		if !sleepContext(ctx, s.client.tokenRetryWait) {
+			break
+		}
+		token, err = s.source.Token()
+	}
+	if err != nil {
+		return nil, &tokenError{err: err}
+	}
+	return token, nil
+}
+
+// tokenError wraps the error of a token source as an ErrTokenAcquisition.
+type tokenError struct {
+	err error
+}
+
+func (e *tokenError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrTokenAcquisition, e.err)
+}
+
+func (e *tokenError) Is(target error) bool {
+	return target == ErrTokenAcquisition
+}
+
+func (e *tokenError) Unwrap() error {
+	return e.err
+}
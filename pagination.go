@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrStopPagination is returned by the function given to Request.Paginate to stop
+// following the next pages without failing.
+var ErrStopPagination = errors.New("stop pagination")
+
+// Paginate performs GET requests to url and to the pages that follow it, given by
+// the rel="next" links of the Link header, as in GitHub-style APIs. fn is called
+// with each page until there's no next link, or until it returns an error, which
+// is returned by Paginate unless it's ErrStopPagination. Request errors are
+// returned as well.
+//
+// Every page is executed as Execute does, with the request headers, auth and
+// callbacks, and is registered in the metrics. The next links carry the whole
+// query string, so the query parameters of the request are only sent to the
+// first page. Set an alias with SetAlias to register every page under the same
+// metric key.
+func (r *Request) Paginate(url string, fn func(resp *Response) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queryParams, rawQuery, commaQuery := r.restyRequest.QueryParam, r.rawQuery, r.commaQuery
+	defer func() {
+		r.restyRequest.QueryParam, r.rawQuery, r.commaQuery = queryParams, rawQuery, commaQuery
+	}()
+
+	for url != "" {
+		resp, err := r.execute(http.MethodGet, url)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp); err != nil {
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			return err
+		}
+
+		url = r.nextPageURL(url, resp.Header())
+		r.restyRequest.QueryParam, r.rawQuery, r.commaQuery = map[string][]string{}, "", nil
+	}
+	return nil
+}
+
+// PaginateCursor performs GET requests to url for each page of a cursor-based API,
+// calling fn with each page. The cursor of the next page is taken from each
+// response with extract and sent as the cursorParam query parameter, along with
+// the other query parameters of the request, until extract returns an empty
+// cursor. Errors of extract and fn are returned, except for ErrStopPagination,
+// which stops following the next pages without failing.
+//
+// Every page is executed as Execute does, with the request headers, auth and
+// callbacks, and is registered in the metrics.
+func (r *Request) PaginateCursor(url, cursorParam string, extract func(resp *Response) (string, error), fn func(*Response) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queryParams := r.restyRequest.QueryParam
+	defer func() {
+		r.restyRequest.QueryParam = queryParams
+	}()
+
+	for {
+		resp, err := r.execute(http.MethodGet, url)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp); err != nil {
+			if errors.Is(err, ErrStopPagination) {
+				return nil
+			}
+			return err
+		}
+
+		cursor, err := extract(resp)
+		if err != nil || cursor == "" {
+			return err
+		}
+
+		// The query parameters are copied, as resty adds the client ones to them.
+		params := make(map[string][]string, len(queryParams)+1)
+		for name, values := range queryParams {
+			params[name] = append([]string(nil), values...)
+		}
+		params[cursorParam] = []string{cursor}
+		r.restyRequest.QueryParam = params
+	}
+}
+
+// nextPageURL returns the rel="next" link of header, resolved against the url of
+// the current page and relative to the host URL when it's under it, or an empty
+// string when there's none.
+func (r *Request) nextPageURL(current string, header http.Header) string {
+	next := nextLink(header)
+	if next == "" {
+		return ""
+	}
+
+	base, err := url.Parse(r.resolveURL(current))
+	if err != nil {
+		return next
+	}
+	reference, err := url.Parse(next)
+	if err != nil {
+		return next
+	}
+	next = base.ResolveReference(reference).String()
+
+	if r.hostURL != nil {
+		host := strings.TrimSuffix(r.hostURL.String(), "/")
+		if strings.HasPrefix(next, host+"/") {
+			return strings.TrimPrefix(next, host)
+		}
+	}
+	return next
+}
+
+// nextLink returns the target of the rel="next" link of the Link header.
+func nextLink(header http.Header) string {
+	for _, value := range header.Values("Link") {
+		for _, link := range strings.Split(value, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range parts[1:] {
+				name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return strings.Trim(target, "<>")
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
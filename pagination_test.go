@@ -0,0 +1,124 @@
+package httpclient_test
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestPaginate(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+
+		page := req.URL.Query().Get("page")
+		switch page {
+		case "1":
+			rw.Header().Set("Link", `</items?page=2>; rel="next", </items?page=3>; rel="last"`)
+		case "2":
+			rw.Header().Add("Link", fmt.Sprintf(`<%s/items?page=1>; rel="prev first"`, server.URL))
+			rw.Header().Add("Link", fmt.Sprintf(`<%s/items?page=3>; rel="next last"`, server.URL))
+		}
+		rw.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+	)
+
+	t.Run("FollowsNextLinks", func(t *testing.T) {
+		var pages []string
+		req := client.NewRequest().SetAlias("items").SetAuthToken("token").SetQueryParams(map[string]string{"page": "1"})
+
+		err := req.Paginate("/items", func(resp *httpclient.Response) error {
+			pages = append(pages, string(resp.Body()))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1", "2", "3"}, pages)
+		assert.Eventually(t, func() bool { return metrics.counter("items.total") == 3 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		var pages []string
+
+		err := client.NewRequest().SetAuthToken("token").Paginate("/items?page=1", func(resp *httpclient.Response) error {
+			pages = append(pages, string(resp.Body()))
+			return httpclient.ErrStopPagination
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1"}, pages)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		errPage := errors.New("page error")
+
+		err := client.NewRequest().SetAuthToken("token").Paginate("/items?page=2", func(resp *httpclient.Response) error {
+			return errPage
+		})
+
+		assert.ErrorIs(t, err, errPage)
+	})
+}
+
+func TestRequestPaginateCursor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "10", req.URL.Query().Get("limit"))
+
+		rw.Header().Set("Content-Type", "application/json")
+		switch req.URL.Query().Get("cursor") {
+		case "":
+			rw.Write([]byte(`{"items": ["a"], "next_cursor": "c1"}`))
+		case "c1":
+			rw.Write([]byte(`{"items": ["b"], "next_cursor": "c2"}`))
+		default:
+			rw.Write([]byte(`{"items": ["c"], "next_cursor": ""}`))
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	type page struct {
+		Items      []string `json:"items"`
+		NextCursor string   `json:"next_cursor"`
+	}
+	extract := func(resp *httpclient.Response) (string, error) {
+		var p page
+		err := resp.Unmarshal(&p)
+		return p.NextCursor, err
+	}
+
+	var items []string
+	req := client.NewRequest().SetQueryParams(map[string]string{"limit": "10"})
+
+	err := req.PaginateCursor("/items", "cursor", extract, func(resp *httpclient.Response) error {
+		var p page
+		if err := resp.Unmarshal(&p); err != nil {
+			return err
+		}
+		items = append(items, p.Items...)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c"}, items)
+	assert.Equal(t, []string{"10"}, req.RestyRequest().QueryParam["limit"])
+	assert.NotContains(t, req.RestyRequest().QueryParam, "cursor")
+}
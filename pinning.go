@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+// ErrPinValidationFailed is returned when the public key of the upstream leaf
+// certificate doesn't match any of the pins set with WithCertificatePinning.
+var ErrPinValidationFailed = errors.New("certificate pin validation failed")
+
+// WithCertificatePinning pins the public key of the upstream leaf certificate:
+// connections are rejected with ErrPinValidationFailed unless the base64 encoded
+// SHA-256 hash of its SubjectPublicKeyInfo is one of pins, as in HPKP. The pins
+// are checked after the usual certificate verification, which still applies, on
+// every connection, including the ones resuming a TLS session. The other
+// transport settings are preserved.
+//
+// A pin can be computed from a certificate with:
+//
+//	openssl x509 -in cert.pem -pubkey -noout | openssl pkey -pubin -outform der | openssl dgst -sha256 -binary | base64
+func WithCertificatePinning(pins ...string) func(*HTTPClient) {
+	pinSet := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pinSet[pin] = true
+	}
+
+	return func(client *HTTPClient) {
+		client.transportOptions = append(client.transportOptions, func(transport *http.Transport) {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			verify := transport.TLSClientConfig.VerifyConnection
+			transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+				if len(state.PeerCertificates) == 0 || !pinSet[certificatePin(state.PeerCertificates[0])] {
+					return ErrPinValidationFailed
+				}
+				if verify != nil {
+					return verify(state)
+				}
+				return nil
+			}
+		})
+	}
+}
+
+// certificatePin returns the base64 encoded SHA-256 hash of the public key of cert.
+func certificatePin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
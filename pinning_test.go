@@ -0,0 +1,67 @@
+package httpclient_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCertificatePinning(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	hash := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(hash[:])
+
+	newClient := func(pins ...string) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithTransport(server.Client().Transport.(*http.Transport).Clone()),
+			httpclient.WithCertificatePinning(pins...),
+		)
+	}
+
+	t.Run("Pinned", func(t *testing.T) {
+		resp, err := newClient("bm90IHRoZSBwaW4=", pin).NewRequest().Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+	})
+
+	t.Run("NotPinned", func(t *testing.T) {
+		_, err := newClient("bm90IHRoZSBwaW4=").NewRequest().Get("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrPinValidationFailed)
+	})
+
+	t.Run("ResumedSession", func(t *testing.T) {
+		var resumed []bool
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = true
+		transport.TLSClientConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+		transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			resumed = append(resumed, state.DidResume)
+			return nil
+		}
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithTransport(transport),
+			httpclient.WithCertificatePinning(pin),
+		)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.NewRequest().Get("/")
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []bool{false, true}, resumed)
+	})
+}
@@ -0,0 +1,25 @@
+// Package protobuf sends and receives Protocol Buffers messages with httpclient.
+// It's kept apart so that only its users depend on the protobuf module.
+package protobuf
+
+import (
+	"github.com/globocom/httpclient"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the Content-Type of the request bodies set with SetBody.
+const ContentType = "application/x-protobuf"
+
+// SetBody sets the body of req to msg encoded with proto.Marshal, and the
+// Content-Type header to ContentType. An encoding error is returned by Execute,
+// wrapped with httpclient.ErrInvalidBody, without sending the request.
+func SetBody(req *httpclient.Request, msg proto.Message) *httpclient.Request {
+	return req.SetBodyFunc(ContentType, func() ([]byte, error) {
+		return proto.Marshal(msg)
+	})
+}
+
+// Unmarshal decodes the body of resp into msg with proto.Unmarshal.
+func Unmarshal(resp *httpclient.Response, msg proto.Message) error {
+	return proto.Unmarshal(resp.Body(), msg)
+}
@@ -0,0 +1,42 @@
+package protobuf_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/globocom/httpclient/protobuf"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobuf(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, protobuf.ContentType, req.Header.Get("Content-Type"))
+
+		body, _ := io.ReadAll(req.Body)
+		var msg wrapperspb.StringValue
+		assert.NoError(t, proto.Unmarshal(body, &msg))
+
+		reply, _ := proto.Marshal(wrapperspb.String("hello, " + msg.GetValue()))
+		rw.Header().Set("Content-Type", protobuf.ContentType)
+		rw.Write(reply)
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	resp, err := protobuf.SetBody(client.NewRequest(), wrapperspb.String("globo")).Post("/")
+
+	var reply wrapperspb.StringValue
+	if assert.NoError(t, err) {
+		assert.NoError(t, protobuf.Unmarshal(resp, &reply))
+		assert.Equal(t, "hello, globo", reply.GetValue())
+	}
+}
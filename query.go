@@ -0,0 +1,62 @@
+package httpclient
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// ArrayFormat is how Request.SetQueryParamsMulti encodes the parameters with
+// multiple values.
+type ArrayFormat int
+
+const (
+	// ArrayFormatRepeat repeats the key for each value, as in "a=1&a=2". It's the
+	// default.
+	ArrayFormatRepeat ArrayFormat = iota
+	// ArrayFormatBrackets repeats the key followed by brackets for each value, as
+	// in "a[]=1&a[]=2". The brackets are percent-encoded.
+	ArrayFormatBrackets
+	// ArrayFormatComma joins the values with commas under a single key, as in
+	// "a=1,2". The commas separating the values are sent as is, while the ones
+	// within the values are percent-encoded.
+	ArrayFormatComma
+)
+
+// WithQueryArrayFormat sets how Request.SetQueryParamsMulti encodes the parameters
+// with multiple values, ArrayFormatRepeat by default.
+func WithQueryArrayFormat(format ArrayFormat) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.queryArrayFormat = format
+	}
+}
+
+// encode returns the query parameter key and values for values of key.
+func (f ArrayFormat) encode(key string, values []string) (string, []string) {
+	switch f {
+	case ArrayFormatBrackets:
+		return key + "[]", values
+	default:
+		return key, values
+	}
+}
+
+// encodeCommaQuery encodes params in the ArrayFormatComma format, sorted by key,
+// escaping each value but not the commas joining them.
+func encodeCommaQuery(params map[string][]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		values := make([]string, len(params[key]))
+		for i, value := range params[key] {
+			values[i] = url.QueryEscape(value)
+		}
+		pairs = append(pairs, url.QueryEscape(key)+"="+strings.Join(values, ","))
+	}
+	return strings.Join(pairs, "&")
+}
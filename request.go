@@ -2,36 +2,124 @@ package httpclient
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http/httptrace"
 	"net/url"
 	"path"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	resty "github.com/go-resty/resty/v2"
 )
 
+// Request is an HTTP request built by HTTPClient.NewRequest.
+//
+// A Request can be executed multiple times, and concurrent executions are safe:
+// they are serialized, as they share the underlying resty request. Setters must
+// not be called while the request is executing.
 type Request struct {
-	alias         string
-	chainCallback Callback
-	hostURL       *url.URL
-	metrics       Metrics
-	restyRequest  *resty.Request
-	startTime     time.Time
+	mu sync.Mutex
+
+	alias               string
+	attempts            int
+	balancer            *loadBalancer
+	bodyBufferSize      int64
+	bodyErr             error
+	bodyLength          bool
+	cache               Cache
+	chainCallback       contextCallback
+	clientName          string
+	clientTrace         *httptrace.ClientTrace
+	clock               func() time.Time
+	errorOnHTTPError    bool
+	executeHooks        []func(*Request, *Response, error)
+	hedgeDelay          time.Duration
+	idempotentRetries   bool
+	jsonDecoder         func([]byte, interface{}) error
+	jsonEncoder         func(interface{}) ([]byte, error)
+	hostURL             *url.URL
+	hostTimeouts        map[string]time.Duration
+	latencyBuckets      []float64
+	metricsSampleRate   float64
+	maxHedges           int
+	method              string
+	rawQuery            string
+	commaQuery          map[string][]string
+	metrics             Metrics
+	metricsAlias        string
+	metricsKeyFormatter func(method, url, alias string) string
+	metricsDisabled     bool
+	requestMiddlewares  []func(*Request) error
+	responseMiddlewares []func(*Response) error
+	restyClient         *resty.Client
+	queryArrayFormat    ArrayFormat
+	random              func() float64
+	restyRequest        *resty.Request
+	signers             []RequestSigner
+	slowRequest         func(*Response)
+	slowThreshold       time.Duration
+	sseReconnect        bool
+	startTime           time.Time
+	streamErr           error
+	streamLine          func([]byte) error
+	syncMetrics         bool
+	traceMetrics        bool
+	transportAuth       bool
+	url                 string
 }
 
 // NewRequest creates a request for the specified HTTP method.
 func (c *HTTPClient) NewRequest() *Request {
-	return &Request{
-		restyRequest:  c.resty.NewRequest(),
-		chainCallback: c.callbackChain,
-		metrics:       c.metrics,
-		hostURL:       c.hostURL,
+	restyRequest := c.resty.NewRequest()
+	if c.baseContext != nil {
+		restyRequest.SetContext(c.baseContext)
+	}
+
+	r := &Request{
+		restyClient:         c.resty,
+		restyRequest:        restyRequest,
+		chainCallback:       c.callbackChain,
+		clock:               c.clock,
+		metrics:             c.metrics,
+		metricsKeyFormatter: c.metricsKeyFormatter,
+		latencyBuckets:      c.latencyBuckets,
+		syncMetrics:         c.syncMetrics,
+		metricsSampleRate:   c.metricsSampleRate,
+		random:              c.random,
+		queryArrayFormat:    c.queryArrayFormat,
+		hostURL:             c.hostURL,
+		balancer:            c.balancer,
+		bodyBufferSize:      c.bodyBufferSize,
+		jsonDecoder:         c.jsonDecoder,
+		jsonEncoder:         c.jsonEncoder,
+		hostTimeouts:        c.hostTimeouts,
+		clientName:          c.name,
+		errorOnHTTPError:    c.errorOnHTTPError,
+		executeHooks:        c.executeHooks,
+		hedgeDelay:          c.hedgeDelay,
+		idempotentRetries:   c.idempotentRetries,
+		clientTrace:         c.clientTrace,
+		traceMetrics:        c.traceMetrics,
+		maxHedges:           c.maxHedges,
+		cache:               c.cache,
+		transportAuth:       c.transportAuth,
+		signers:             c.signers,
+		requestMiddlewares:  c.requestMiddlewares,
+		responseMiddlewares: c.responseMiddlewares,
+		slowThreshold:       c.slowThreshold,
+		slowRequest:         c.slowRequest,
 	}
+	return r
 }
 
-// HostURL returns the setted host url.
+// HostURL returns the setted host url. With WithLoadBalancer, it's the base URL of
+// the replica the last execution was sent to.
 func (r *Request) HostURL() *url.URL {
 	return r.hostURL
 }
@@ -42,12 +130,154 @@ func (r *Request) SetAlias(alias string) *Request {
 	return r
 }
 
+// Method returns the HTTP method of the request.
+// It is only available once the request is executed.
+func (r *Request) Method() string {
+	return r.method
+}
+
+// URL returns the url given to execute the request.
+// It is only available once the request is executed.
+func (r *Request) URL() string {
+	return r.url
+}
+
+// MetricsAlias returns the key used to register the request metrics.
+// It is only available once the request is executed.
+func (r *Request) MetricsAlias() string {
+	return r.metricsAlias
+}
+
+// DisableMetrics skips the metrics of the request, including the transport ones
+// of WithTransportMetrics, e.g. for health checks that would drown the metrics of
+// the actual traffic.
+func (r *Request) DisableMetrics() *Request {
+	r.metricsDisabled = true
+	return r
+}
+
 // SetBody sets the body for the request.
+//
+// An io.Reader body is rewound on retries to its initial position when it
+// implements io.Seeker. Otherwise, what's read from it is kept in memory, up to
+// the limit set with WithBodyBufferSize, to be replayed; retries of larger bodies
+// fail with ErrBodyNotReplayable. Use SetBodyReader to stream huge bodies without
+// buffering them.
 func (r *Request) SetBody(body interface{}) *Request {
+	r.resetBody()
+	if reader, ok := body.(io.Reader); ok {
+		if sized, ok := reader.(interface{ Len() int }); ok && r.restyRequest.Header.Get("Content-Length") == "" {
+			r.setBodyLength(int64(sized.Len()))
+		}
+		body = newBodyReader(reader, r.bodyBufferSize)
+	}
 	r.restyRequest.SetBody(body)
 	return r
 }
 
+// SetBodyJSON sets the body of the request to v encoded as JSON with encoding/json,
+// and the Content-Type header to application/json. An encoding error is returned
+// by Execute, wrapped with ErrInvalidBody, without sending the request.
+func (r *Request) SetBodyJSON(v interface{}) *Request {
+	return r.SetBodyFunc("application/json", func() ([]byte, error) {
+		if r.jsonEncoder != nil {
+			return r.jsonEncoder(v)
+		}
+		return json.Marshal(v)
+	})
+}
+
+// SetBodyFunc sets the body to the one returned by encode, with the given
+// Content-Type, e.g. to send formats that aren't supported by SetBody. When
+// encode fails, Execute returns its error wrapped with ErrInvalidBody, without
+// sending the request.
+func (r *Request) SetBodyFunc(contentType string, encode func() ([]byte, error)) *Request {
+	r.resetBody()
+	body, err := encode()
+	if err != nil {
+		r.bodyErr = &bodyError{err: err}
+		return r
+	}
+
+	r.restyRequest.SetHeader("Content-Type", contentType)
+	r.restyRequest.SetBody(body)
+	return r
+}
+
+// SetRawBody sets the body to data, sent verbatim, with the exact Content-Type
+// given instead of a detected one, e.g. for binary uploads and signed payloads.
+// data must not be modified until the request is executed.
+func (r *Request) SetRawBody(data []byte, contentType string) *Request {
+	return r.SetBodyFunc(contentType, func() ([]byte, error) {
+		return data, nil
+	})
+}
+
+// SetFormURLEncoded sets the body to the URL encoded values, with the
+// "application/x-www-form-urlencoded" Content-Type. The values are merged with the
+// ones set with resty's SetFormData, on the request or the client, and replace
+// the body set by the other setters.
+func (r *Request) SetFormURLEncoded(values url.Values) *Request {
+	r.resetBody()
+	r.restyRequest.Body = nil
+	if r.restyRequest.FormData == nil {
+		r.restyRequest.FormData = url.Values{}
+	}
+	for key, vals := range values {
+		for _, value := range vals {
+			r.restyRequest.FormData.Add(key, value)
+		}
+	}
+	return r
+}
+
+// SetBodyReader streams the reader as the request body instead of buffering it.
+// When contentLength >= 0 it is sent as the Content-Length, otherwise the body
+// is sent with chunked transfer encoding.
+//
+// On retries, the reader is rewound to its initial position when it implements
+// io.Seeker; otherwise the retry fails with ErrBodyNotReplayable.
+func (r *Request) SetBodyReader(reader io.Reader, contentLength int64) *Request {
+	r.resetBody()
+	r.restyRequest.SetBody(newBodyReader(reader, 0))
+	if contentLength >= 0 {
+		r.setBodyLength(contentLength)
+	}
+	return r
+}
+
+// resetBody clears the error and the Content-Length header left by the previous
+// body setter, so they don't apply to the new body.
+func (r *Request) resetBody() {
+	r.bodyErr = nil
+	if r.bodyLength {
+		r.restyRequest.Header.Del("Content-Length")
+		r.bodyLength = false
+	}
+}
+
+// setBodyLength sets the Content-Length header of a reader body, which is
+// applied to the request when it's sent.
+func (r *Request) setBodyLength(length int64) {
+	r.restyRequest.SetHeader("Content-Length", strconv.FormatInt(length, 10))
+	r.bodyLength = true
+}
+
+// SetResult sets a pointer to be populated with the response body when the
+// request succeeds. The body is decoded based on the response content type.
+func (r *Request) SetResult(result interface{}) *Request {
+	r.restyRequest.SetResult(result)
+	return r
+}
+
+// SetError sets a pointer to be populated with the response body when the
+// request fails with an HTTP error status. The body is decoded based on the
+// response content type.
+func (r *Request) SetError(err interface{}) *Request {
+	r.restyRequest.SetError(err)
+	return r
+}
+
 // SetContext sets the context for the request.
 func (r *Request) SetContext(context context.Context) *Request {
 	r.restyRequest.SetContext(context)
@@ -60,6 +290,39 @@ func (r *Request) SetHeader(name, value string) *Request {
 	return r
 }
 
+// SetContentType sets the Content-Type header for the request.
+func (r *Request) SetContentType(contentType string) *Request {
+	r.restyRequest.SetHeader("Content-Type", contentType)
+	return r
+}
+
+// SetAccept sets the Accept header for the request.
+func (r *Request) SetAccept(contentType string) *Request {
+	r.restyRequest.SetHeader("Accept", contentType)
+	return r
+}
+
+// SetExpectContinue sets the "Expect: 100-continue" header, so the body is only
+// sent after the server accepts the request headers. It requires a non-zero
+// timeout set with WithExpectContinueTimeout.
+func (r *Request) SetExpectContinue() *Request {
+	r.restyRequest.SetHeader("Expect", "100-continue")
+	return r
+}
+
+// SetIdempotencyKey sets the Idempotency-Key header, allowing servers to
+// deduplicate retries of non-idempotent requests, such as POST.
+func (r *Request) SetIdempotencyKey(key string) *Request {
+	r.restyRequest.SetHeader("Idempotency-Key", key)
+	return r
+}
+
+// WithGeneratedIdempotencyKey sets the Idempotency-Key header to a random UUID.
+// The key is generated once, so every retry of the request sends the same key.
+func (r *Request) WithGeneratedIdempotencyKey() *Request {
+	return r.SetIdempotencyKey(newUUID())
+}
+
 // SetBasicAuth sets the basic authentication header for the request.
 func (r *Request) SetBasicAuth(username, password string) *Request {
 	r.restyRequest.SetBasicAuth(username, password)
@@ -72,12 +335,44 @@ func (r *Request) SetAuthToken(bearer string) *Request {
 	return r
 }
 
+// SetAuthScheme sets the scheme of the Authorization header of the token, "Bearer"
+// by default, overriding the one set with WithAuthScheme.
+func (r *Request) SetAuthScheme(scheme string) *Request {
+	r.restyRequest.SetAuthScheme(scheme)
+	return r
+}
+
 // SetQueryParams sets parameters to form a query string for the request.
 func (r *Request) SetQueryParams(params map[string]string) *Request {
 	r.restyRequest.SetQueryParams(params)
 	return r
 }
 
+// SetQueryParamsMulti sets parameters with multiple values to form a query string
+// for the request, encoded in the format set with WithQueryArrayFormat.
+func (r *Request) SetQueryParamsMulti(params map[string][]string) *Request {
+	for key, values := range params {
+		if r.queryArrayFormat == ArrayFormatComma {
+			if r.commaQuery == nil {
+				r.commaQuery = map[string][]string{}
+			}
+			r.commaQuery[key] = append([]string(nil), values...)
+			continue
+		}
+		key, values = r.queryArrayFormat.encode(key, values)
+		r.restyRequest.QueryParam[key] = append([]string(nil), values...)
+	}
+	return r
+}
+
+// SetQueryString sets the raw query string of the request, sent exactly as given,
+// keeping the order and encoding of the parameters. It's useful for APIs that sign
+// the query string. Parameters set with SetQueryParams are appended after it.
+func (r *Request) SetQueryString(raw string) *Request {
+	r.rawQuery = strings.TrimPrefix(strings.TrimSpace(raw), "?")
+	return r
+}
+
 // SetPathParams sets multiple key-value pairs to form the path for the request.
 func (r *Request) SetPathParams(params map[string]string) *Request {
 	r.restyRequest.SetPathParams(params)
@@ -109,44 +404,289 @@ func (r *Request) Delete(url string) (*Response, error) {
 	return r.Execute("DELETE", url)
 }
 
+// Send performs an HTTP request given an url, using the method set by helpers
+// such as GraphQL. It defaults to GET when no method was set.
+func (r *Request) Send(url string) (*Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	method := r.restyRequest.Method
+	if method == "" {
+		method = "GET"
+	}
+	return r.execute(method, url)
+}
+
 // Execute performs the HTTP request with given HTTP method and URL.
 // It also registers metrics, metrics fields are:
 // host/alias occurrences, response time,
 // response status code, quantity of occurrence of a circuit breaker open and
 // errors occurred.
+//
+// Concurrent executions of the same request are serialized.
 func (r *Request) Execute(method string, url string) (*Response, error) {
-	metricsAlias := url
-	if len(r.alias) > 0 {
-		metricsAlias = r.alias
-	} else if r.hostURL != nil {
-		hostname := r.hostURL.Hostname()
-		metricsAlias = fmt.Sprintf("%s.%s", method, path.Join(hostname, url))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.execute(method, url)
+}
+
+func (r *Request) execute(method string, url string) (resp *Response, err error) {
+	r.method = method
+	r.url = url
+	r.attempts = 0
+	r.pickHost()
+	if len(r.executeHooks) > 0 {
+		defer func() {
+			for _, hook := range r.executeHooks {
+				hook(r, resp, err)
+			}
+		}()
 	}
 
-	metricsAlias = strings.Replace(metricsAlias, ".", "-", -1)
+	// Body setters keep their errors to fail fast, before any network work.
+	if r.bodyErr != nil {
+		return nil, r.bodyErr
+	}
+
+	// The execution replaces the context with derived ones, cancelled on return.
+	defer r.restyRequest.SetContext(r.restyRequest.Context())
+
+	metricsAlias := r.metricsKey(method, url)
+	r.metricsAlias = metricsAlias
+	if r.metrics != nil {
+		key := metricsAlias
+		if r.metricsDisabled {
+			key = ""
+		}
+		ctx := withMetricsKey(r.restyRequest.Context(), key)
+		if r.balancer != nil {
+			ctx = withBalancedMetricsKey(ctx, r.balancedMetricsKey(method, url))
+		}
+		r.restyRequest.SetContext(ctx)
+	}
 
-	return registerMetrics(metricsAlias, r.metrics, func() (*Response, error) {
-		execute := func() (*Response, error) {
-			r.startTime = time.Now()
-			restyResponse, err := r.restyRequest.Execute(method, url)
+	var cacheKey string
+	if r.cache != nil && method == "GET" && r.streamLine == nil {
+		cacheKey = r.cacheKey(url)
+		if resp, ok := r.cachedResponse(cacheKey); ok {
+			return resp, nil
+		}
+	}
+
+	if timeout, ok := r.hostTimeout(url); ok {
+		ctx, cancel := context.WithTimeout(r.restyRequest.Context(), timeout)
+		defer cancel()
+		r.restyRequest.SetContext(ctx)
+	}
+
+	if r.idempotentRetries && !r.retryable(method) {
+		r.restyRequest.SetContext(withRetryGuard(r.restyRequest.Context()))
+	}
+
+	resp, err = r.registerMetrics(r.restyRequest.Context(), func() (*Response, error) {
+		execute := func(ctx context.Context) (*Response, error) {
+			ctx = r.withTrace(ctx)
+			r.restyRequest.SetContext(ctx)
+			for _, middleware := range r.requestMiddlewares {
+				if err := middleware(r); err != nil {
+					return nil, err
+				}
+			}
+
+			r.startTime = r.clock()
+			restyResponse, err := r.send(ctx, method, url)
 			if restyResponse == nil {
 				return nil, err
 			}
-			return wrapResponse(r, restyResponse), err
+			r.recordHost(restyResponse.Request.URL)
+
+			resp := wrapResponse(r, restyResponse)
+			if r.slowRequest != nil && resp.ResponseTime() > r.slowThreshold {
+				r.slowRequest(resp)
+			}
+			if err != nil {
+				return resp, err
+			}
+			for _, middleware := range r.responseMiddlewares {
+				if err := middleware(resp); err != nil {
+					return resp, err
+				}
+			}
+			if r.streamLine != nil {
+				r.streamErr = r.readStream(resp)
+			}
+			return resp, nil
 		}
 
-		return r.chainCallback(execute)
+		return r.chainCallback(r.restyRequest.Context(), execute)
 	})
+
+	if cacheKey != "" && resp != nil && err == nil {
+		r.cacheResponse(cacheKey, resp)
+	}
+
+	if r.errorOnHTTPError && resp != nil && err == nil && (resp.statusCode < 200 || resp.statusCode > 299) {
+		err = newHTTPError(resp)
+	}
+
+	return resp, err
+}
+
+// send executes the resty request, hedging it when enabled.
+func (r *Request) send(ctx context.Context, method, url string) (*resty.Response, error) {
+	url = r.withRawQuery(r.balancedURL(url))
+	if r.shouldHedge(method) {
+		return r.executeHedged(ctx, method, url)
+	}
+
+	previousAttempts := r.restyRequest.Attempt
+	restyResponse, err := r.restyRequest.Execute(method, url)
+	if guard := requestRetryGuard(ctx); guard != nil && errors.Is(err, errRetryPrevented) {
+		r.restyRequest.Attempt = previousAttempts + 1
+		restyResponse, err = guard.response, guard.err
+	}
+	r.countAttempts(previousAttempts)
+	return restyResponse, err
+}
+
+// retryable reports whether a request with method can be safely retried, as it's
+// idempotent or has an Idempotency-Key header.
+func (r *Request) retryable(method string) bool {
+	return isIdempotent(method) || r.restyRequest.Header.Get("Idempotency-Key") != ""
+}
+
+// metricsKey returns the key used to register the metrics of the request.
+func (r *Request) metricsKey(method, url string) string {
+	return r.hostMetricsKey(method, url, r.hostURL)
+}
+
+// hostMetricsKey returns the metrics key of the request sent to host.
+func (r *Request) hostMetricsKey(method, url string, host *url.URL) string {
+	alias := r.alias
+	if len(alias) == 0 {
+		alias = aliasFromContext(r.restyRequest.Context())
+	}
+	if r.metricsKeyFormatter != nil {
+		return r.metricsKeyFormatter(method, r.resolveHostURL(url, host), alias)
+	}
+
+	metricsAlias := url
+	if len(alias) > 0 {
+		metricsAlias = alias
+	} else if host != nil {
+		hostname := host.Hostname()
+		if r.balancer != nil {
+			hostname = host.Host
+		}
+		metricsAlias = fmt.Sprintf("%s.%s", method, path.Join(hostname, url))
+	}
+
+	metricsAlias = strings.Replace(metricsAlias, ".", "-", -1)
+	if len(r.clientName) > 0 {
+		metricsAlias = fmt.Sprintf("%s.%s", strings.Replace(r.clientName, ".", "-", -1), metricsAlias)
+	}
+	return metricsAlias
+}
+
+// resolveURL returns url with the path parameters replaced and the host URL
+// prepended when it's relative.
+func (r *Request) resolveURL(url string) string {
+	return r.resolveHostURL(url, r.hostURL)
 }
 
-func registerMetrics(key string, metrics Metrics, f func() (*Response, error)) (*Response, error) {
+// resolveHostURL is resolveURL with host as the host URL.
+func (r *Request) resolveHostURL(url string, host *url.URL) string {
+	for param, value := range r.restyRequest.PathParams {
+		url = strings.Replace(url, "{"+param+"}", value, -1)
+	}
+	for param, value := range r.restyRequest.RawPathParams {
+		url = strings.Replace(url, "{"+param+"}", value, -1)
+	}
+	if host != nil && !strings.Contains(url, "://") {
+		url = strings.TrimSuffix(host.String(), "/") + "/" + strings.TrimPrefix(url, "/")
+	}
+	return url
+}
+
+// withRawQuery returns url with the query string set by SetQueryString, followed
+// by the parameters set by SetQueryParamsMulti in the ArrayFormatComma format,
+// whose commas resty would escape.
+func (r *Request) withRawQuery(url string) string {
+	rawQuery := r.rawQuery
+	if len(r.commaQuery) > 0 {
+		if rawQuery != "" {
+			rawQuery += "&"
+		}
+		rawQuery += encodeCommaQuery(r.commaQuery)
+	}
+
+	if rawQuery == "" {
+		return url
+	}
+	if strings.Contains(url, "?") {
+		return url + "&" + rawQuery
+	}
+	return url + "?" + rawQuery
+}
+
+// hostTimeout returns the timeout configured for the target host of rawURL.
+func (r *Request) hostTimeout(rawURL string) (time.Duration, bool) {
+	if len(r.hostTimeouts) == 0 {
+		return 0, false
+	}
+
+	var hostname string
+	if strings.Contains(rawURL, "://") {
+		target, err := url.Parse(rawURL)
+		if err != nil {
+			return 0, false
+		}
+		hostname = target.Hostname()
+	} else if r.hostURL != nil {
+		hostname = r.hostURL.Hostname()
+	}
+
+	timeout, ok := r.hostTimeouts[hostname]
+	return timeout, ok
+}
+
+// countAttempts adds the attempts made by resty since previousAttempts.
+// resty resets its counter to one when retries are disabled.
+func (r *Request) countAttempts(previousAttempts int) {
+	attempts := r.restyRequest.Attempt - previousAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	r.attempts += attempts
+}
+
+// registerMetrics executes f registering the metrics of its response under the
+// metrics key of the request, set once f returns, along with its retries: the
+// "retry_count" series gets the retries of each request, and the "retry" counter
+// is incremented for each retry.
+func (r *Request) registerMetrics(ctx context.Context, f func() (*Response, error)) (*Response, error) {
 	resp, err := f()
+	key := r.metricsAlias
 
-	if metrics != nil {
-		go func(resp *Response, err error) {
+	if metrics := r.metrics; metrics != nil && !r.metricsDisabled {
+		buckets, sampled, attempts := r.latencyBuckets, r.sampled(), r.attempts
+		emit := func(resp *Response, err error) {
 			attrs := map[string]string{}
+			if attempts > 0 {
+				// The retries of both the resty client and the resilience callbacks.
+				metrics.PushToSeries(fmt.Sprintf("%s.%s", key, "retry_count"), float64(attempts-1))
+				for i := 1; i < attempts; i++ {
+					metrics.IncrCounter(fmt.Sprintf("%s.%s", key, "retry"))
+				}
+			}
 			if resp != nil {
-				metrics.PushToSeries(fmt.Sprintf("%s.%s", key, "response_time"), resp.ResponseTime().Seconds())
+				if sampled {
+					pushResponseTime(ctx, metrics, fmt.Sprintf("%s.%s", key, "response_time"), resp.ResponseTime().Seconds())
+				}
+				if len(buckets) > 0 {
+					metrics.IncrCounter(fmt.Sprintf("%s.latency_bucket.%s", key, latencyBucket(buckets, resp.ResponseTime().Seconds())))
+				}
 				if resp.statusCode != 0 {
 					metrics.IncrCounter(fmt.Sprintf("%s.status.%d", key, resp.StatusCode()))
 					attrs["status"] = fmt.Sprintf("%d", resp.StatusCode())
@@ -154,14 +694,75 @@ func registerMetrics(key string, metrics Metrics, f func() (*Response, error)) (
 			}
 			if err != nil {
 				if errors.Is(err, ErrCircuitOpen) {
+					// Requests rejected by the breaker have no status, so they're
+					// tagged apart from the failed ones in the total.
 					metrics.IncrCounter(fmt.Sprintf("%s.%s", key, "circuit_open"))
+					attrs["outcome"] = "circuit_open"
+				} else if errors.Is(err, ErrTokenAcquisition) {
+					metrics.IncrCounter(fmt.Sprintf("%s.%s", key, "oauth_error"))
 				} else {
 					metrics.IncrCounter(fmt.Sprintf("%s.%s", key, "errors"))
 				}
 			}
 			metrics.IncrCounterWithAttrs(fmt.Sprintf("%s.%s", key, "total"), attrs)
-		}(resp, err)
+		}
+
+		r.emitMetrics(func(Metrics) { emit(resp, err) })
 	}
 
 	return resp, err
 }
+
+// emitMetrics registers metrics with emit, inline with WithSynchronousMetrics or
+// in a new goroutine otherwise. Nothing is registered when the metrics of the
+// request are disabled.
+func (r *Request) emitMetrics(emit func(metrics Metrics)) {
+	if r.metrics == nil || r.metricsDisabled {
+		return
+	}
+	if r.syncMetrics {
+		emit(r.metrics)
+	} else {
+		go emit(r.metrics)
+	}
+}
+
+// emitCounter increments the counter name of the request metrics key.
+func (r *Request) emitCounter(name string) {
+	key := fmt.Sprintf("%s.%s", r.metricsAlias, name)
+	r.emitMetrics(func(metrics Metrics) { metrics.IncrCounter(key) })
+}
+
+// emitSeries pushes value to the series name of the request metrics key.
+func (r *Request) emitSeries(name string, value float64) {
+	key := fmt.Sprintf("%s.%s", r.metricsAlias, name)
+	r.emitMetrics(func(metrics Metrics) { metrics.PushToSeries(key, value) })
+}
+
+// sampled reports whether the response time of the request is registered,
+// according to the rate set with WithMetricsSampling.
+func (r *Request) sampled() bool {
+	return r.metricsSampleRate >= 1 || r.random() < r.metricsSampleRate
+}
+
+// pushResponseTime pushes the response time to the series, with the request ID as
+// exemplar when metrics supports it.
+func pushResponseTime(ctx context.Context, metrics Metrics, name string, seconds float64) {
+	if exemplarMetrics, ok := metrics.(ExemplarMetrics); ok {
+		if id := requestID(ctx); id != "" {
+			exemplarMetrics.PushToSeriesWithExemplar(name, seconds, map[string]string{"request_id": id})
+			return
+		}
+	}
+	metrics.PushToSeries(name, seconds)
+}
+
+// latencyBucket returns the name of the first bucket in the sorted bounds that
+// fits seconds, or "+Inf" when none does.
+func latencyBucket(bounds []float64, seconds float64) string {
+	i := sort.SearchFloat64s(bounds, seconds)
+	if i == len(bounds) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(bounds[i], 'f', -1, 64)
+}
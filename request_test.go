@@ -1,13 +1,23 @@
 package httpclient_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/globocom/httpclient"
 
+	resty "github.com/go-resty/resty/v2"
+	"github.com/slok/goresilience/circuitbreaker"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,13 +37,16 @@ func TestRequest(t *testing.T) {
 	defer server.Close()
 
 	tests := map[string]func(*httpclient.Request) func(*testing.T){
-		"SetBody":      testSetBody,
-		"SetHeader":    testSetHeader,
-		"SetBasicAuth": testSetBasicAuth,
-		"Get":          testGet,
-		"Post":         testPost,
-		"Put":          testPut,
-		"Delete":       testDelete,
+		"SetBody":        testSetBody,
+		"SetHeader":      testSetHeader,
+		"SetContentType": testSetContentType,
+		"SetAccept":      testSetAccept,
+		"SetBasicAuth":   testSetBasicAuth,
+		"SetQueryString": testSetQueryString,
+		"Get":            testGet,
+		"Post":           testPost,
+		"Put":            testPut,
+		"Delete":         testDelete,
 	}
 
 	client := httpclient.NewHTTPClient(
@@ -77,6 +90,26 @@ func testSetHeader(target *httpclient.Request) func(*testing.T) {
 	}
 }
 
+func testSetContentType(target *httpclient.Request) func(*testing.T) {
+	return func(t *testing.T) {
+		target.SetContentType("application/json")
+		_, err := target.SetBody([]byte(`{}`)).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "application/json", gReq.Header.Get("Content-Type"))
+	}
+}
+
+func testSetAccept(target *httpclient.Request) func(*testing.T) {
+	return func(t *testing.T) {
+		target.SetAccept("application/xml")
+		_, err := target.Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "application/xml", gReq.Header.Get("Accept"))
+	}
+}
+
 func testSetBasicAuth(target *httpclient.Request) func(*testing.T) {
 	return func(t *testing.T) {
 		target.SetBasicAuth("Username", "Password")
@@ -92,6 +125,17 @@ func testSetBasicAuth(target *httpclient.Request) func(*testing.T) {
 	}
 }
 
+func testSetQueryString(target *httpclient.Request) func(*testing.T) {
+	return func(t *testing.T) {
+		target.SetQueryString("z=1&a=hello%20world&sig=AbC%2B")
+		target.SetQueryParams(map[string]string{"page": "2"})
+		_, err := target.Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "z=1&a=hello%20world&sig=AbC%2B&page=2", gReq.URL.RawQuery)
+	}
+}
+
 func testGet(target *httpclient.Request) func(*testing.T) {
 	return func(t *testing.T) {
 		target.SetBody([]byte("test"))
@@ -131,3 +175,819 @@ func testDelete(target *httpclient.Request) func(*testing.T) {
 		assert.Equal(t, "DELETE", gReq.Method)
 	}
 }
+
+func TestRequestSetResultAndError(t *testing.T) {
+	type payload struct {
+		Message string `json:"message"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if req.URL.Path == "/fail" {
+			rw.WriteHeader(http.StatusBadRequest)
+			rw.Write([]byte(`{"message":"bad request"}`))
+			return
+		}
+		rw.Write([]byte(`{"message":"ok"}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Result", func(t *testing.T) {
+		result := &payload{}
+		resp, err := client.NewRequest().SetResult(result).Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", result.Message)
+		assert.Equal(t, result, resp.Result())
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		errPayload := &payload{}
+		resp, err := client.NewRequest().SetError(errPayload).Get("/fail")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "bad request", errPayload.Message)
+		assert.Equal(t, errPayload, resp.Error())
+	})
+}
+
+func TestRequestJSONEncoder(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		payload, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(payload))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithJSONEncoder(func(v interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			encoder.SetEscapeHTML(false)
+			err := encoder.Encode(v)
+			return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), err
+		}),
+	)
+	body := map[string]string{"html": "<b>"}
+
+	_, err := client.NewRequest().SetBodyJSON(body).Post("/")
+	assert.NoError(t, err)
+
+	_, err = client.NewRequest().SetBody(body).Post("/")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{`{"html":"<b>"}`, `{"html":"<b>"}`}, bodies)
+}
+
+func TestRequestSetRawBody(t *testing.T) {
+	var body []byte
+	var contentType []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		contentType = req.Header.Values("Content-Type")
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	for _, tt := range []struct {
+		data        []byte
+		contentType string
+	}{
+		{[]byte{0x00, 0xff, 0x10}, "application/octet-stream"},
+		{[]byte(`{ "b": 1,  "a": 2 }`), "application/vnd.api+json"},
+		{[]byte(`<html></html>`), "text/plain; charset=utf-8"},
+	} {
+		_, err := client.NewRequest().SetRawBody(tt.data, tt.contentType).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.data, body)
+		assert.Equal(t, []string{tt.contentType}, contentType)
+	}
+}
+
+func TestRequestSetFormURLEncoded(t *testing.T) {
+	var form url.Values
+	var contentType []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		form, contentType = req.PostForm, req.Header.Values("Content-Type")
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	req := client.NewRequest().SetBodyJSON(map[string]int{"id": 1}).SetFormURLEncoded(url.Values{"tag": {"a", "b"}})
+	req.RestyRequest().SetFormData(map[string]string{"name": "globo"})
+	_, err := req.Post("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, url.Values{"tag": {"a", "b"}, "name": {"globo"}}, form)
+	assert.Equal(t, []string{"application/x-www-form-urlencoded"}, contentType)
+}
+
+func TestRequestDryRun(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithBasicAuth("user", "pass"),
+		httpclient.WithRequestMiddleware(func(r *httpclient.Request) error {
+			r.RestyRequest().SetHeader("X-Middleware", "true")
+			return nil
+		}),
+	)
+
+	req, err := client.NewRequest().SetQueryParams(map[string]string{"q": "1"}).SetBodyJSON(map[string]int{"id": 1}).DryRun(http.MethodPost, "/items")
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPost, req.Method)
+	assert.Equal(t, server.URL+"/items?q=1", req.URL.String())
+	assert.Equal(t, "true", req.Header.Get("X-Middleware"))
+	user, pass, _ := req.BasicAuth()
+	assert.Equal(t, "user:pass", user+":"+pass)
+	body, _ := io.ReadAll(req.Body)
+	assert.Equal(t, `{"id":1}`, string(body))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	t.Run("Resilience", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		metrics := newMetricsRecorder()
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithLoadBalancer([]string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}, httpclient.RoundRobin),
+			httpclient.WithMetrics(metrics),
+			httpclient.WithSynchronousMetrics(),
+			httpclient.WithTransportMetrics(),
+			httpclient.WithRetries(2, time.Millisecond, time.Millisecond),
+			httpclient.WithCircuitBreaker(circuitbreaker.Config{
+				ErrorPercentThresholdToOpen: 1,
+				MinimumRequestToOpen:        1,
+				WaitDurationInOpenState:     time.Minute,
+			}),
+		)
+		request := client.NewRequest().SetAlias("items")
+
+		resp, err := request.Get("/items")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Attempts())
+
+		for i := 0; i < 3; i++ {
+			req, err := request.DryRun(http.MethodGet, "/items")
+			assert.NoError(t, err)
+			assert.Equal(t, request.HostURL().String()+"/items", req.URL.String())
+		}
+
+		resp, err = request.Get("/items")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, resp.Attempts())
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+		assert.Equal(t, 2, metrics.counter("items.total"))
+		assert.Equal(t, []float64{0, 0}, metrics.values("items.retry_count"))
+		assert.Len(t, metrics.values("items.conn_reused"), 2)
+	})
+
+	t.Run("RetryConditions", func(t *testing.T) {
+		var signs int32
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithRetries(2, time.Millisecond, time.Millisecond),
+			httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool { return err != nil }),
+			httpclient.WithRequestSigner(httpclient.RequestSignerFunc(func(req *http.Request, body []byte) error {
+				atomic.AddInt32(&signs, 1)
+				return nil
+			})),
+		)
+
+		_, err := client.NewRequest().DryRun(http.MethodGet, "/items")
+
+		assert.NoError(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&signs))
+	})
+
+	t.Run("BodyReader", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(&httpclient.LoggerAdapter{Writer: io.Discard}, httpclient.WithHostURL(server.URL))
+		request := client.NewRequest().SetBodyReader(io.LimitReader(strings.NewReader("payload"), 7), 7)
+
+		req, err := request.DryRun(http.MethodPost, "/items")
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, "payload", string(body))
+
+		_, err = request.Post("/items")
+		assert.ErrorIs(t, err, httpclient.ErrBodyNotReplayable)
+	})
+}
+
+func TestRequestSetQueryParamsMulti(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte(req.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	for name, tt := range map[string]struct {
+		format   httpclient.ArrayFormat
+		expected string
+	}{
+		"Repeat":   {httpclient.ArrayFormatRepeat, "a=1&a=2&b=3"},
+		"Brackets": {httpclient.ArrayFormatBrackets, "a%5B%5D=1&a%5B%5D=2&b%5B%5D=3"},
+		"Comma":    {httpclient.ArrayFormatComma, "a=1,2&b=3"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := httpclient.NewHTTPClient(
+				&httpclient.LoggerAdapter{Writer: io.Discard},
+				httpclient.WithHostURL(server.URL),
+				httpclient.WithQueryArrayFormat(tt.format),
+			)
+
+			resp, err := client.NewRequest().
+				SetQueryParamsMulti(map[string][]string{"a": {"1", "2"}, "b": {"3"}}).
+				Get("/")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(resp.Body()))
+		})
+	}
+
+	t.Run("CommaWithinValue", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithQueryArrayFormat(httpclient.ArrayFormatComma),
+		)
+
+		resp, err := client.NewRequest().
+			SetQueryString("c=4").
+			SetQueryParams(map[string]string{"d": "5"}).
+			SetQueryParamsMulti(map[string][]string{"a": {"1,2", "3 4"}}).
+			Get("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "c=4&a=1%2C2,3+4&d=5", string(resp.Body()))
+	})
+}
+
+func TestRequestSetBodyJSON(t *testing.T) {
+	var body, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		payload, _ := io.ReadAll(req.Body)
+		body, contentType = string(payload), req.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Map", func(t *testing.T) {
+		_, err := client.NewRequest().SetBodyJSON(map[string]interface{}{"id": 1}).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"id":1}`, body)
+		assert.Equal(t, "application/json", contentType)
+	})
+
+	t.Run("MarshalError", func(t *testing.T) {
+		body = ""
+		resp, err := client.NewRequest().SetBodyJSON(map[string]interface{}{"fn": func() {}}).Post("/")
+
+		var unsupported *json.UnsupportedTypeError
+		assert.ErrorIs(t, err, httpclient.ErrInvalidBody)
+		assert.ErrorAs(t, err, &unsupported)
+		assert.EqualError(t, err, "invalid request body: json: unsupported type: func()")
+		assert.Nil(t, resp)
+		assert.Empty(t, body)
+	})
+}
+
+func TestRequestSetBodyReader(t *testing.T) {
+	var bodies []string
+	var contentLength int64
+	var transferEncoding []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		contentLength = req.ContentLength
+		transferEncoding = req.TransferEncoding
+		if req.URL.Path == "/retry" && len(bodies) < 2 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithRetries(1, time.Millisecond, time.Millisecond),
+		httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool {
+			return resp != nil && resp.StatusCode() == http.StatusServiceUnavailable
+		}),
+	)
+
+	t.Run("ContentLength", func(t *testing.T) {
+		bodies = nil
+		_, err := client.NewRequest().SetBodyReader(io.LimitReader(strings.NewReader("payload"), 7), 7).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"payload"}, bodies)
+		assert.Equal(t, int64(7), contentLength)
+		assert.Empty(t, transferEncoding)
+	})
+
+	t.Run("Chunked", func(t *testing.T) {
+		bodies = nil
+		_, err := client.NewRequest().SetBodyReader(io.LimitReader(strings.NewReader("payload"), 7), -1).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"payload"}, bodies)
+		assert.Equal(t, []string{"chunked"}, transferEncoding)
+	})
+
+	t.Run("RetrySeeker", func(t *testing.T) {
+		bodies = nil
+		reader := strings.NewReader("--payload")
+		reader.Seek(2, io.SeekStart)
+		resp, err := client.NewRequest().SetBodyReader(reader, 7).Post("/retry")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("RetryNotReplayable", func(t *testing.T) {
+		bodies = nil
+		_, err := client.NewRequest().SetBodyReader(io.LimitReader(strings.NewReader("payload"), 7), 7).Post("/retry")
+
+		assert.ErrorIs(t, err, httpclient.ErrBodyNotReplayable)
+		assert.Equal(t, []string{"payload"}, bodies)
+	})
+}
+
+func TestRequestSetBodyReaderRetries(t *testing.T) {
+	var bodies []string
+	var contentLength int64
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		contentLength = req.ContentLength
+		if len(bodies) < 2 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	newClient := func(options ...httpclient.Opt) *httpclient.HTTPClient {
+		options = append(options,
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithRetries(1, time.Millisecond, time.Millisecond),
+			httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool {
+				return resp != nil && resp.StatusCode() == http.StatusServiceUnavailable
+			}),
+		)
+		return httpclient.NewHTTPClient(&httpclient.LoggerAdapter{Writer: io.Discard}, options...)
+	}
+
+	t.Run("Buffered", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().NewRequest().SetBody(io.LimitReader(strings.NewReader("payload"), 7)).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+	})
+
+	t.Run("ContentLength", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().NewRequest().SetBody(bytes.NewBufferString("payload")).Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+		assert.Equal(t, int64(7), contentLength)
+	})
+
+	t.Run("ContentLengthReplaced", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().NewRequest().
+			SetBody(bytes.NewBufferString("long payload")).
+			SetBody(bytes.NewBufferString("payload")).
+			Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+		assert.Equal(t, int64(7), contentLength)
+	})
+
+	t.Run("ContentLengthCleared", func(t *testing.T) {
+		bodies = nil
+		resp, err := newClient().NewRequest().
+			SetBody(bytes.NewBufferString("long payload")).
+			SetBody(io.LimitReader(strings.NewReader("payload"), 7)).
+			Post("/")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode())
+		assert.Equal(t, []string{"payload", "payload"}, bodies)
+		assert.Equal(t, int64(-1), contentLength)
+	})
+
+	t.Run("BufferExceeded", func(t *testing.T) {
+		bodies = nil
+		client := newClient(httpclient.WithBodyBufferSize(4))
+		_, err := client.NewRequest().SetBody(io.LimitReader(strings.NewReader("payload"), 7)).Post("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrBodyNotReplayable)
+		assert.Equal(t, []string{"payload"}, bodies)
+	})
+
+	t.Run("BufferDisabled", func(t *testing.T) {
+		bodies = nil
+		client := newClient(httpclient.WithBodyBufferSize(0))
+		_, err := client.NewRequest().SetBody(io.LimitReader(strings.NewReader("payload"), 7)).Post("/")
+
+		assert.ErrorIs(t, err, httpclient.ErrBodyNotReplayable)
+		assert.Equal(t, []string{"payload"}, bodies)
+	})
+}
+
+func TestRequestConcurrentExecute(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		rw.Write([]byte(req.Header.Get("X-Shared")))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(newMetricsRecorder()),
+	)
+	request := client.NewRequest().SetHeader("X-Shared", "value")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := request.Get("/")
+			if assert.NoError(t, err) {
+				assert.Equal(t, "value", string(resp.Body()))
+				assert.Equal(t, 1, resp.Attempts())
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), atomic.LoadInt32(&calls))
+}
+
+func TestRequestContextMetricsAlias(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+	)
+	ctx := httpclient.WithMetricsAlias(context.Background(), "profile")
+
+	resp, err := client.NewRequest().SetContext(ctx).Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "profile", resp.Request().MetricsAlias())
+
+	resp, err = client.NewRequest().SetContext(ctx).SetAlias("account").Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "account", resp.Request().MetricsAlias())
+
+	assert.Eventually(t, func() bool { return metrics.counter("profile.total") == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRequestMetricsKeyFormatter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithClientName("users"),
+		httpclient.WithMetricsKeyFormatter(func(method, url, alias string) string {
+			if alias != "" {
+				return "api." + alias
+			}
+			return "api." + method + "." + strings.TrimPrefix(url, server.URL+"/")
+		}),
+	)
+
+	resp, err := client.NewRequest().SetPathParams(map[string]string{"id": "1"}).Get("/users/{id}")
+	assert.NoError(t, err)
+	assert.Equal(t, "api.GET.users/1", resp.Request().MetricsAlias())
+
+	resp, err = client.NewRequest().SetAlias("user.profile").Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "api.user.profile", resp.Request().MetricsAlias())
+
+	assert.Eventually(t, func() bool { return metrics.counter("api.user.profile.total") == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRequestLatencyBuckets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		bounds []float64
+		bucket string
+	}{
+		{bounds: []float64{60, 0.5, 30}, bucket: "0.5"},
+		{bounds: []float64{1e-9}, bucket: "+Inf"},
+	} {
+		metrics := newMetricsRecorder()
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithMetrics(metrics),
+			httpclient.WithLatencyBuckets(tc.bounds),
+		)
+
+		_, err := client.NewRequest().SetAlias("users").Get(server.URL)
+
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool { return metrics.counter("users.latency_bucket."+tc.bucket) == 1 }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 1, metrics.seriesLen("users.response_time"))
+	}
+}
+
+func TestRequestSynchronousMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithMetrics(metrics),
+		httpclient.WithSynchronousMetrics(),
+	)
+
+	_, err := client.NewRequest().SetAlias("users").Get(server.URL)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, metrics.counter("users.total"))
+	assert.Equal(t, 1, metrics.counter("users.status.200"))
+	assert.Equal(t, 1, metrics.seriesLen("users.response_time"))
+
+	t.Run("Cache", func(t *testing.T) {
+		cached := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Cache-Control", "max-age=60")
+		}))
+		defer cached.Close()
+
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithMetrics(metrics),
+			httpclient.WithSynchronousMetrics(),
+			httpclient.WithResponseCache(httpclient.NewMemoryCache()),
+		)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.NewRequest().SetAlias("cached").Get(cached.URL)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, 1, metrics.counter("cached.cache_hit"))
+	})
+}
+
+func TestRequestRetryMetrics(t *testing.T) {
+	var times int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/retried" && atomic.AddInt32(&times, 1) < 3 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithSynchronousMetrics(),
+		httpclient.WithRetries(3, time.Millisecond, time.Millisecond),
+		httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool {
+			return resp.StatusCode() == http.StatusServiceUnavailable
+		}),
+	)
+
+	_, err := client.NewRequest().SetAlias("retried").Get("/retried")
+	assert.NoError(t, err)
+	_, err = client.NewRequest().SetAlias("single").Get("/")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []float64{2}, metrics.values("retried.retry_count"))
+	assert.Equal(t, 2, metrics.counter("retried.retry"))
+	assert.Equal(t, []float64{0}, metrics.values("single.retry_count"))
+	assert.Zero(t, metrics.counter("single.retry"))
+}
+
+type exemplarRecorder struct {
+	*metricsRecorder
+	exemplars chan map[string]string
+}
+
+func (m *exemplarRecorder) PushToSeriesWithExemplar(name string, value float64, exemplar map[string]string) {
+	m.PushToSeries(name, value)
+	m.exemplars <- exemplar
+}
+
+func TestRequestMetricsExemplar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := &exemplarRecorder{metricsRecorder: newMetricsRecorder(), exemplars: make(chan map[string]string, 1)}
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithMetrics(metrics),
+		httpclient.WithSynchronousMetrics(),
+	)
+
+	ctx := context.WithValue(context.Background(), "request.id", "42")
+	_, err := client.NewRequest().SetContext(ctx).SetAlias("users").Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"request_id": "42"}, <-metrics.exemplars)
+
+	_, err = client.NewRequest().SetAlias("users").Get(server.URL)
+	assert.NoError(t, err)
+	assert.Empty(t, metrics.exemplars)
+	assert.Equal(t, 2, metrics.seriesLen("users.response_time"))
+}
+
+func TestRequestMetricsSampling(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithMetrics(metrics),
+		httpclient.WithSynchronousMetrics(),
+		httpclient.WithMetricsSampling(0),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.NewRequest().SetAlias("users").Get(server.URL)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 3, metrics.counter("users.total"))
+	assert.Equal(t, 3, metrics.counter("users.status.200"))
+	assert.Equal(t, 0, metrics.seriesLen("users.response_time"))
+}
+
+func TestRequestDisableMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithMetrics(metrics),
+		httpclient.WithSynchronousMetrics(),
+		httpclient.WithTransportMetrics(),
+	)
+
+	_, err := client.NewRequest().SetAlias("health").DisableMetrics().Get(server.URL)
+	assert.NoError(t, err)
+	_, err = client.NewRequest().SetAlias("users").Get(server.URL)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, metrics.counter("health.total"))
+	assert.Empty(t, metrics.values("health.conn_reused"))
+	assert.Equal(t, 1, metrics.counter("users.total"))
+	assert.Len(t, metrics.values("users.conn_reused"), 1)
+
+	t.Run("Cache", func(t *testing.T) {
+		cached := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Cache-Control", "max-age=60")
+		}))
+		defer cached.Close()
+
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithMetrics(metrics),
+			httpclient.WithSynchronousMetrics(),
+			httpclient.WithResponseCache(httpclient.NewMemoryCache()),
+			httpclient.WithTraceMetrics(),
+		)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.NewRequest().SetAlias("cached").DisableMetrics().Get(cached.URL)
+			assert.NoError(t, err)
+		}
+		assert.Zero(t, metrics.counter("cached.cache_hit"))
+		assert.Zero(t, metrics.seriesLen("cached.ttfb"))
+	})
+}
+
+func TestRequestClientNameMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithClientName("users.api"),
+	)
+
+	resp, err := client.NewRequest().SetAlias("profile").Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "users-api.profile", resp.Request().MetricsAlias())
+	assert.Eventually(t, func() bool { return metrics.counter("users-api.profile.total") == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRequestErrorOnHTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/missing" {
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte("not found"))
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithErrorOnHTTPError(),
+	)
+
+	resp, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	resp, err = client.NewRequest().Get("/missing")
+	var httpErr *httpclient.HTTPError
+	if assert.ErrorAs(t, err, &httpErr) {
+		assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+		assert.Equal(t, "404 Not Found", httpErr.Status)
+		assert.Equal(t, []byte("not found"), httpErr.Body)
+		assert.EqualError(t, err, "http error: 404 Not Found")
+	}
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+}
+
+func TestRequestIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		if len(keys) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithRetries(1, time.Millisecond, time.Millisecond),
+		httpclient.WithRetryConditions(func(resp *resty.Response, err error) bool {
+			return resp.StatusCode() == http.StatusServiceUnavailable
+		}),
+	)
+
+	_, err := client.NewRequest().SetIdempotencyKey("42").Post("/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"42", "42"}, keys)
+
+	keys = nil
+	_, err = client.NewRequest().WithGeneratedIdempotencyKey().Post("/")
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, keys[0])
+		assert.Equal(t, keys[0], keys[1])
+	}
+}
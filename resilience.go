@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"context"
+	"time"
+
+	"github.com/slok/goresilience/bulkhead"
+	"github.com/slok/goresilience/circuitbreaker"
+	goresilienceErrors "github.com/slok/goresilience/errors"
+)
+
+// ErrBulkheadFull is returned when a request waits longer than the bulkhead
+// MaxWaitTime set in ResilienceConfig to be executed.
+var ErrBulkheadFull = goresilienceErrors.ErrTimeoutWaitingForExecution
+
+// ResilienceConfig configures the resilience strategies composed by WithResilience.
+// Zero values disable each strategy.
+type ResilienceConfig struct {
+	// Bulkhead limits the number of concurrent requests.
+	Bulkhead *bulkhead.Config
+	// CircuitBreaker stops sending requests while the upstream is failing.
+	CircuitBreaker *circuitbreaker.Config
+	// Retries is the number of retries after an error occurred.
+	Retries int
+	// RetryWaitTime is the amount of time to wait for a new retry.
+	RetryWaitTime time.Duration
+	// ExponentialBackoff applies exponential backoff with jitter to RetryWaitTime.
+	ExponentialBackoff bool
+	// Timeout limits the duration of each attempt, returning ErrTimeout.
+	Timeout time.Duration
+}
+
+// WithResilience composes the resilience strategies in a fixed order, regardless
+// of the order of the options. From the outermost to the innermost:
+//
+//	bulkhead -> circuit breaker -> retries -> timeout -> request
+//
+// So each attempt has its own timeout, the circuit breaker records the result
+// after all retries, and the bulkhead limits whole executions, including the
+// circuit breaker fast failures.
+//
+// This functionality relies on https://github.com/slok/goresilience library.
+func WithResilience(cfg ResilienceConfig) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		if cfg.Timeout > 0 {
+			client.chainContextCallback(timeoutCallback(cfg.Timeout))
+		}
+		if cfg.Retries > 0 {
+			client.chainContextCallback(backoffCallback(cfg.Retries, cfg.RetryWaitTime, cfg.ExponentialBackoff))
+		}
+		if cfg.CircuitBreaker != nil {
+			client.chainContextCallback(circuitBreakerCallback(client, *cfg.CircuitBreaker))
+		}
+		if cfg.Bulkhead != nil {
+			client.chainContextCallback(bulkheadCallback(*cfg.Bulkhead))
+		}
+	}
+}
+
+func bulkheadCallback(config bulkhead.Config) contextCallback {
+	runner := bulkhead.New(config)
+	return func(ctx context.Context, fn func(context.Context) (*Response, error)) (*Response, error) {
+		var resp *Response
+		err := runner.Run(ctx, func(ctx context.Context) error {
+			var err error
+			resp, err = fn(ctx)
+			return err
+		})
+		return resp, err
+	}
+}
@@ -0,0 +1,96 @@
+package httpclient_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/slok/goresilience/bulkhead"
+	"github.com/slok/goresilience/circuitbreaker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResilience(t *testing.T) {
+	t.Run("RetriesInsideCircuitBreaker", func(t *testing.T) {
+		errUnavailable := errors.New("unavailable")
+		attempts := 0
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithRequestMiddleware(func(*httpclient.Request) error {
+				attempts++
+				return errUnavailable
+			}),
+			httpclient.WithResilience(httpclient.ResilienceConfig{
+				CircuitBreaker: &circuitbreaker.Config{
+					ErrorPercentThresholdToOpen: 1,
+					MinimumRequestToOpen:        1,
+					WaitDurationInOpenState:     time.Minute,
+				},
+				Retries:       2,
+				RetryWaitTime: time.Millisecond,
+			}),
+		)
+
+		_, err := client.NewRequest().Get("http://localhost")
+		assert.ErrorIs(t, err, errUnavailable)
+		assert.Equal(t, 3, attempts)
+
+		_, err = client.NewRequest().Get("http://localhost")
+		assert.Equal(t, httpclient.ErrCircuitOpen, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("TimeoutPerAttempt", func(t *testing.T) {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithResilience(httpclient.ResilienceConfig{
+				Retries:       1,
+				RetryWaitTime: time.Millisecond,
+				Timeout:       20 * time.Millisecond,
+			}),
+		)
+
+		_, err := client.NewRequest().Get(server.URL)
+		assert.Equal(t, httpclient.ErrTimeout, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Bulkhead", func(t *testing.T) {
+		started := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithResilience(httpclient.ResilienceConfig{
+				Bulkhead: &bulkhead.Config{Workers: 1, MaxWaitTime: 10 * time.Millisecond},
+			}),
+		)
+
+		done := make(chan error)
+		go func() {
+			_, err := client.NewRequest().Get(server.URL)
+			done <- err
+		}()
+		<-started
+
+		_, err := client.NewRequest().Get(server.URL)
+		assert.Equal(t, httpclient.ErrBulkheadFull, err)
+		assert.NoError(t, <-done)
+	})
+}
@@ -1,19 +1,38 @@
 package httpclient
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	resty "github.com/go-resty/resty/v2"
 )
 
+// ErrUnsupportedContentType is returned by Response.Unmarshal when the response
+// Content-Type can't be decoded.
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
 type Response struct {
+	attempts     int
 	statusCode   int
+	status       string
 	body         []byte
+	rawBody      io.ReadCloser
 	header       http.Header
 	cookies      []*http.Cookie
 	request      *Request
 	responseTime time.Duration
+	sentAt       time.Time
+	receivedAt   time.Time
+	result       interface{}
+	err          interface{}
 }
 
 // StatusCode returns the response status code.
@@ -21,6 +40,11 @@ func (r Response) StatusCode() int {
 	return r.statusCode
 }
 
+// Status returns the response status, e.g. "200 OK".
+func (r Response) Status() string {
+	return r.status
+}
+
 // Body returns the response body.
 func (r Response) Body() []byte {
 	return r.body
@@ -46,23 +70,112 @@ func (r Response) Cookie(name string) *http.Cookie {
 	return nil
 }
 
+// Result returns the value set by Request.SetResult, populated with the
+// decoded response body on success.
+func (r Response) Result() interface{} {
+	return r.result
+}
+
+// Error returns the value set by Request.SetError, populated with the
+// decoded response body on HTTP error statuses.
+func (r Response) Error() interface{} {
+	return r.err
+}
+
 // Request returns the received request.
 func (r Response) Request() *Request {
 	return r.request
 }
 
+// Attempts returns how many times the request was sent until this response was
+// received, counting both resty retries and callback retries such as WithBackoff.
+// It is one-indexed: a response received on the first try returns 1.
+func (r Response) Attempts() int {
+	return r.attempts
+}
+
 // ResponseTime returns the request response time.
 func (r Response) ResponseTime() time.Duration {
 	return r.responseTime
 }
 
+// SentAt returns the time the request was sent.
+func (r Response) SentAt() time.Time {
+	return r.sentAt
+}
+
+// ReceivedAt returns the time the response was received.
+func (r Response) ReceivedAt() time.Time {
+	return r.receivedAt
+}
+
+// Unmarshal decodes the response body into v based on the response Content-Type.
+// JSON and XML types, including "+json" and "+xml" suffixes, are decoded with the
+// standard library. Form-encoded bodies are decoded into a *url.Values or a
+// *map[string]string, keeping the first value of each key.
+// Other types return an error wrapping ErrUnsupportedContentType.
+func (r Response) Unmarshal(v interface{}) error {
+	contentType := r.header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return r.JSON(v)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xml.Unmarshal(r.body, v)
+	case mediaType == "application/x-www-form-urlencoded":
+		return unmarshalForm(r.body, v)
+	}
+	return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+}
+
+// JSON decodes the response body into v as JSON, regardless of its Content-Type,
+// with the decoder set with WithJSONDecoder, or encoding/json by default.
+func (r Response) JSON(v interface{}) error {
+	if r.request != nil && r.request.jsonDecoder != nil {
+		return r.request.jsonDecoder(r.body, v)
+	}
+	return json.Unmarshal(r.body, v)
+}
+
+func unmarshalForm(body []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return err
+	}
+
+	switch target := v.(type) {
+	case *url.Values:
+		*target = values
+	case *map[string]string:
+		*target = make(map[string]string, len(values))
+		for key := range values {
+			(*target)[key] = values.Get(key)
+		}
+	default:
+		return fmt.Errorf("cannot unmarshal form into %T", v)
+	}
+	return nil
+}
+
 func wrapResponse(request *Request, restyResponse *resty.Response) *Response {
+	receivedAt := request.clock()
 	return &Response{
+		attempts:     request.attempts,
 		statusCode:   restyResponse.StatusCode(),
+		status:       restyResponse.Status(),
 		header:       restyResponse.Header(),
 		body:         restyResponse.Body(),
+		rawBody:      restyResponse.RawBody(),
 		cookies:      restyResponse.Cookies(),
 		request:      request,
-		responseTime: time.Since(request.startTime),
+		responseTime: receivedAt.Sub(request.startTime),
+		sentAt:       request.startTime,
+		receivedAt:   receivedAt,
+		result:       restyResponse.Result(),
+		err:          restyResponse.Error(),
 	}
 }
@@ -1,10 +1,15 @@
 package httpclient_test
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/globocom/httpclient"
 	"github.com/stretchr/testify/assert"
@@ -19,12 +24,275 @@ func TestResponse(t *testing.T) {
 		httpclient.WithHostURL(server.URL),
 	)
 
+	before := time.Now()
 	target, err := client.NewRequest().Get("/")
 	if assert.NoError(t, err) {
+		assert.False(t, target.SentAt().Before(before))
+		assert.False(t, target.ReceivedAt().After(time.Now()))
+		assert.Equal(t, target.ResponseTime(), target.ReceivedAt().Sub(target.SentAt()))
 		assert.Equal(t, http.StatusOK, target.StatusCode())
+		assert.Equal(t, "200 OK", target.Status())
 		assert.Equal(t, []byte("OK"), target.Body())
 		assert.Equal(t, "test", target.Header().Get("Testheader"))
 		assert.Equal(t, &http.Cookie{Name: "testCookie", Value: "test", Raw: "testCookie=test"}, target.Cookies()[0])
 		assert.Equal(t, "test", target.Cookie("testCookie").Value)
 	}
 }
+
+func TestResponseTimeWithClock(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	ticks := 0
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithClock(func() time.Time {
+			ticks++
+			return start.Add(time.Duration(ticks-1) * 150 * time.Millisecond)
+		}),
+	)
+
+	target, err := client.NewRequest().Get("/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, start, target.SentAt())
+		assert.Equal(t, start.Add(150*time.Millisecond), target.ReceivedAt())
+		assert.Equal(t, 150*time.Millisecond, target.ResponseTime())
+	}
+}
+
+func TestResponseUnmarshal(t *testing.T) {
+	type payload struct {
+		Message string `json:"message" xml:"message"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/json":
+			rw.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+			rw.Write([]byte(`{"message":"json"}`))
+		case "/xml":
+			rw.Header().Set("Content-Type", "application/xml")
+			rw.Write([]byte(`<payload><message>xml</message></payload>`))
+		case "/form":
+			rw.Header().Set("Content-Type", "application/x-www-form-urlencoded")
+			rw.Write([]byte(`message=form&message=other`))
+		default:
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.Write([]byte(`plain`))
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	for _, name := range []string{"json", "xml"} {
+		t.Run(name, func(t *testing.T) {
+			resp, err := client.NewRequest().Get("/" + name)
+			assert.NoError(t, err)
+
+			var target payload
+			assert.NoError(t, resp.Unmarshal(&target))
+			assert.Equal(t, name, target.Message)
+		})
+	}
+
+	t.Run("form", func(t *testing.T) {
+		resp, err := client.NewRequest().Get("/form")
+		assert.NoError(t, err)
+
+		var values url.Values
+		assert.NoError(t, resp.Unmarshal(&values))
+		assert.Equal(t, []string{"form", "other"}, values["message"])
+
+		var fields map[string]string
+		assert.NoError(t, resp.Unmarshal(&fields))
+		assert.Equal(t, map[string]string{"message": "form"}, fields)
+
+		assert.Error(t, resp.Unmarshal(&payload{}))
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		resp, err := client.NewRequest().Get("/plain")
+		assert.NoError(t, err)
+
+		err = resp.Unmarshal(&payload{})
+		assert.ErrorIs(t, err, httpclient.ErrUnsupportedContentType)
+		assert.ErrorContains(t, err, "text/plain")
+	})
+}
+
+func TestResponseJSONDecoder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"id": 1, "unknown": true}`))
+	}))
+	defer server.Close()
+
+	type user struct {
+		ID int `json:"id"`
+	}
+	strict := func(data []byte, v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(&httpclient.LoggerAdapter{Writer: io.Discard})
+		resp, err := client.NewRequest().Get(server.URL)
+
+		var u user
+		if assert.NoError(t, err) {
+			assert.NoError(t, resp.JSON(&u))
+			assert.Equal(t, user{ID: 1}, u)
+		}
+	})
+
+	t.Run("Custom", func(t *testing.T) {
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithJSONDecoder(strict),
+		)
+		resp, err := client.NewRequest().SetResult(&user{}).Get(server.URL)
+
+		assert.Error(t, err)
+		var u user
+		assert.EqualError(t, resp.JSON(&u), `json: unknown field "unknown"`)
+		assert.EqualError(t, resp.Unmarshal(&u), `json: unknown field "unknown"`)
+	})
+}
+
+func TestResponseJSONPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Write([]byte(`{"data":{"items":[{"id":1,"tags":["a","b"]},{"id":2}],"total":2,"next":null}}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	resp, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+
+	for path, expected := range map[string]interface{}{
+		"data.items[0].id":      float64(1),
+		"data.items[0].tags[1]": "b",
+		"data.total":            float64(2),
+		"data.next":             nil,
+		"data.items[1]":         map[string]interface{}{"id": float64(2)},
+	} {
+		value, err := resp.JSONPath(path)
+
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, value, path)
+	}
+
+	_, err = resp.JSONPath("data.items[2].id")
+	assert.ErrorIs(t, err, httpclient.ErrJSONPathNotFound)
+	assert.EqualError(t, err, `json path not found: "data.items[2].id": index 2 out of range of data.items`)
+
+	_, err = resp.JSONPath("data.missing")
+	assert.ErrorIs(t, err, httpclient.ErrJSONPathNotFound)
+	assert.EqualError(t, err, `json path not found: "data.missing": key "missing" missing from data`)
+
+	_, err = resp.JSONPath("data.total.value")
+	assert.NotErrorIs(t, err, httpclient.ErrJSONPathNotFound)
+	assert.EqualError(t, err, `json path "data.total.value": data.total is a number, not an object`)
+
+	_, err = resp.JSONPath("data[0]")
+	assert.EqualError(t, err, `json path "data[0]": data is an object, not an array`)
+
+	_, err = resp.JSONPath("data.items[x]")
+	assert.EqualError(t, err, `json path "data.items[x]": invalid index "x"`)
+}
+
+func TestDecode(t *testing.T) {
+	type user struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/invalid" {
+			rw.Write([]byte(`{"id":"1"}`))
+			return
+		}
+		rw.Write([]byte(`{"id":1,"name":"globo"}`))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	resp, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+
+	u, err := httpclient.Decode[user](resp)
+
+	assert.NoError(t, err)
+	assert.Equal(t, user{ID: 1, Name: "globo"}, u)
+
+	resp, err = client.NewRequest().Get("/invalid")
+	assert.NoError(t, err)
+
+	_, err = httpclient.Decode[user](resp)
+
+	assert.Error(t, err)
+
+	_, err = httpclient.Decode[user](nil)
+
+	assert.EqualError(t, err, "decode: nil response")
+}
+
+func TestGet(t *testing.T) {
+	type user struct {
+		ID int `json:"id"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte(`{"error":"not found"}`))
+		case "/slow":
+			<-req.Context().Done()
+		default:
+			rw.Write([]byte(`{"id":1}`))
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithErrorOnHTTPError(),
+	)
+
+	u, resp, err := httpclient.Get[user](client, context.Background(), "/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, user{ID: 1}, u)
+	assert.Equal(t, http.StatusOK, resp.StatusCode())
+
+	_, resp, err = httpclient.Get[user](client, context.Background(), "/missing")
+
+	var httpErr *httpclient.HTTPError
+	assert.ErrorAs(t, err, &httpErr)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, _, err = httpclient.Get[user](client, ctx, "/slow")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,31 @@
+package httpclient
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("metrics sampling", func() {
+	newRequest := func(rate, random float64) *Request {
+		client := NewHTTPClient(nil, WithMetricsSampling(rate))
+		client.random = func() float64 { return random }
+		return client.NewRequest()
+	}
+
+	It("samples every request by default", func() {
+		client := NewHTTPClient(nil)
+		client.random = func() float64 { return 0.99 }
+
+		Expect(client.NewRequest().sampled()).To(BeTrue())
+	})
+
+	It("samples the requests whose random value is below the rate", func() {
+		Expect(newRequest(0.25, 0.1).sampled()).To(BeTrue())
+		Expect(newRequest(0.25, 0.25).sampled()).To(BeFalse())
+		Expect(newRequest(0.25, 0.9).sampled()).To(BeFalse())
+	})
+
+	It("samples no request with a zero rate", func() {
+		Expect(newRequest(0, 0).sampled()).To(BeFalse())
+	})
+})
@@ -0,0 +1,93 @@
+package httpclient_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHMACSigning(t *testing.T) {
+	signaturePattern := regexp.MustCompile(`^HMAC-SHA256 KeyId=key-id,Timestamp=(1640995200),Signature=([0-9a-f]+)$`)
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, `{"name":"test"}`, string(body))
+
+		matches := signaturePattern.FindStringSubmatch(req.Header.Get("X-Signature"))
+		if !assert.Len(t, matches, 3) {
+			return
+		}
+
+		bodyHash := sha256.Sum256(body)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		fmt.Fprintf(mac, "POST\n/items?page=2\n%s\n%s", matches[1], hex.EncodeToString(bodyHash[:]))
+		assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), matches[2])
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithHMACSigning("key-id", "secret", "X-Signature"),
+		httpclient.WithClock(func() time.Time {
+			return time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+		}),
+	)
+
+	_, err := client.NewRequest().
+		SetQueryParams(map[string]string{"page": "2"}).
+		SetBody(map[string]string{"name": "test"}).
+		Post("/items")
+
+	assert.NoError(t, err)
+}
+
+func TestRequestSigner(t *testing.T) {
+	var calls int
+	var signature, custom string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		calls++
+		signature, custom = req.Header.Get("Authorization"), req.Header.Get("X-Custom-Signature")
+	}))
+	defer server.Close()
+
+	errSigning := errors.New("signing")
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithRequestSigner(&httpclient.HMACSigner{
+			KeyID:  "key-id",
+			Secret: "secret",
+			Now:    func() time.Time { return time.Unix(1640995200, 0) },
+		}),
+		httpclient.WithRequestSigner(httpclient.RequestSignerFunc(func(req *http.Request, body []byte) error {
+			if string(body) == "fail" {
+				return errSigning
+			}
+			req.Header.Set("X-Custom-Signature", req.Method+" "+string(body))
+			return nil
+		})),
+	)
+
+	_, err := client.NewRequest().SetBody("body").Post("/")
+
+	assert.NoError(t, err)
+	assert.Regexp(t, `^HMAC-SHA256 KeyId=key-id,Timestamp=1640995200,Signature=[0-9a-f]+$`, signature)
+	assert.Equal(t, "POST body", custom)
+
+	_, err = client.NewRequest().SetBody("fail").Post("/")
+
+	assert.ErrorIs(t, err, errSigning)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,205 @@
+// Package sigv4 signs httpclient requests with AWS Signature Version 4.
+// It's kept apart so that only its users depend on the AWS SDK module.
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/globocom/httpclient"
+)
+
+const (
+	awsV4Algorithm  = "AWS4-HMAC-SHA256"
+	awsV4TimeFormat = "20060102T150405Z"
+	awsV4DateFormat = "20060102"
+)
+
+// Signer is the httpclient.RequestSigner of WithAWSV4Signing and
+// WithAWSV4SigningProvider.
+type Signer struct {
+	// Credentials provides the credentials, retrieved for each signature.
+	Credentials aws.CredentialsProvider
+	Region      string
+	Service     string
+	// Now returns the signing time, time.Now when nil.
+	Now func() time.Time
+}
+
+// Sign retrieves the credentials, with the request context, and sets the AWS
+// Signature Version 4 headers of req.
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	creds, err := s.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return fmt.Errorf("aws credentials: %w", err)
+	}
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	signAWSV4(req, body, creds, s.Region, s.Service, now())
+	return nil
+}
+
+// WithAWSV4Signing signs every request with AWS Signature Version 4, including
+// the payload hash, using creds for the given region and service (e.g.
+// "execute-api"). Each attempt is signed right before it is sent, so retries get a
+// fresh signature. Use WithAWSV4SigningProvider for temporary credentials.
+//
+// More information about the signing process:
+// https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func WithAWSV4Signing(creds aws.Credentials, region, service string) httpclient.Opt {
+	return WithAWSV4SigningProvider(aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return creds, nil
+	}), region, service)
+}
+
+// WithAWSV4SigningProvider is like WithAWSV4Signing, retrieving the credentials
+// from provider for each signature, so temporary credentials are refreshed as they
+// expire. provider should cache them, e.g. with aws.NewCredentialsCache, as
+// config.LoadDefaultConfig does. A failure to retrieve them fails the request
+// without sending it.
+func WithAWSV4SigningProvider(provider aws.CredentialsProvider, region, service string) httpclient.Opt {
+	return func(client *httpclient.HTTPClient) {
+		httpclient.WithRequestSigner(&Signer{
+			Credentials: provider,
+			Region:      region,
+			Service:     service,
+			Now:         client.Now,
+		})(client)
+	}
+}
+
+func signAWSV4(req *http.Request, body []byte, creds aws.Credentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(awsV4TimeFormat)
+	scope := strings.Join([]string{now.Format(awsV4DateFormat), region, service, "aws4_request"}, "/")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := awsV4CanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsV4CanonicalURI(req.URL, service),
+		awsV4CanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{awsV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.Format(awsV4DateFormat))
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsV4CanonicalHeaders returns the signed headers list and the canonical headers
+// block, covering the host, the content type and every X-Amz-* header.
+func awsV4CanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + headers[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// awsV4CanonicalURI encodes the path segments, twice for every service but S3.
+func awsV4CanonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsV4Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func awsV4CanonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(query))
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsV4Escape(key)+"="+awsV4Escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsV4Escape encodes s as specified by RFC 3986, keeping only unreserved characters.
+func awsV4Escape(s string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(s) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-_.~", b) >= 0 {
+			escaped.WriteByte(b)
+		} else {
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
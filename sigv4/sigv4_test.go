@@ -0,0 +1,120 @@
+package sigv4
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+type contextKey string
+
+func TestSignAWSV4(t *testing.T) {
+	// Test vectors from the AWS Signature Version 4 test suite.
+	creds := aws.Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	t.Run("WithoutQuery", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+
+		signAWSV4(req, nil, creds, "us-east-1", "service", now)
+
+		assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+		assert.Equal(t, "AWS4-HMAC-SHA256 "+
+			"Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+			"SignedHeaders=host;x-amz-date, "+
+			"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+			req.Header.Get("Authorization"))
+	})
+
+	t.Run("SortedQuery", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+
+		signAWSV4(req, nil, creds, "us-east-1", "service", now)
+
+		assert.Contains(t, req.Header.Get("Authorization"),
+			"Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500")
+	})
+
+	t.Run("SessionToken", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+		sessionCreds := creds
+		sessionCreds.SessionToken = "token"
+
+		signAWSV4(req, nil, sessionCreds, "us-east-1", "service", now)
+
+		assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+		assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+	})
+}
+
+func TestAWSV4Signing(t *testing.T) {
+	var authorization, date string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authorization, date = req.Header.Get("Authorization"), req.Header.Get("X-Amz-Date")
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		WithAWSV4Signing(aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, "us-east-1", "execute-api"),
+		httpclient.WithClock(func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }),
+	)
+
+	_, err := client.NewRequest().SetBody("payload").Post("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "20150830T123600Z", date)
+	assert.Contains(t, authorization, "Credential=AKID/20150830/us-east-1/execute-api/aws4_request,")
+}
+
+func TestAWSV4SigningProvider(t *testing.T) {
+	var authorization, securityToken string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		authorization, securityToken = req.Header.Get("Authorization"), req.Header.Get("X-Amz-Security-Token")
+	}))
+	defer server.Close()
+
+	var retrievals int
+	errExpired := errors.New("expired")
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		WithAWSV4SigningProvider(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			retrievals++
+			if ctx.Value(contextKey("fail")) != nil {
+				return aws.Credentials{}, errExpired
+			}
+			return aws.Credentials{
+				AccessKeyID:     fmt.Sprintf("AKID%d", retrievals),
+				SecretAccessKey: "secret",
+				SessionToken:    fmt.Sprintf("token-%d", retrievals),
+			}, nil
+		}), "us-east-1", "execute-api"),
+	)
+
+	for i := 1; i <= 2; i++ {
+		_, err := client.NewRequest().Get("/")
+
+		assert.NoError(t, err)
+		assert.Contains(t, authorization, fmt.Sprintf("Credential=AKID%d/", i))
+		assert.Equal(t, fmt.Sprintf("token-%d", i), securityToken)
+	}
+
+	ctx := context.WithValue(context.Background(), contextKey("fail"), true)
+	_, err := client.NewRequest().SetContext(ctx).Get("/")
+
+	assert.ErrorIs(t, err, errExpired)
+}
@@ -0,0 +1,139 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSSERetry is the reconnection delay used until the server sets one.
+const defaultSSERetry = 3 * time.Second
+
+// SSEEvent is an event received from a Server-Sent Events stream.
+type SSEEvent struct {
+	// ID is the last event ID set by the stream.
+	ID string
+	// Event is the event type, empty for the default "message" type.
+	Event string
+	// Data is the event payload, with multiple data lines joined by "\n".
+	Data string
+	// Retry is the reconnection delay set by the event, if any.
+	Retry time.Duration
+}
+
+// SetSSEReconnect makes SSE reconnect when the stream ends or the connection
+// fails, sending the Last-Event-ID header with the last received event ID.
+// It waits 3 seconds before reconnecting, unless the server sets a retry delay.
+func (r *Request) SetSSEReconnect(enabled bool) *Request {
+	r.sseReconnect = enabled
+	return r
+}
+
+// SSE opens a Server-Sent Events (text/event-stream) stream and calls handler
+// for each event until the stream ends, handler returns an error or the request
+// context is done. See SetSSEReconnect to keep the stream open.
+func (r *Request) SSE(url string, handler func(event SSEEvent) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.restyRequest.SetHeader("Accept", "text/event-stream")
+	r.restyRequest.SetHeader("Cache-Control", "no-cache")
+
+	var handlerErr error
+	parser := &sseParser{retry: defaultSSERetry}
+	for {
+		err := r.stream(url, func(line []byte) error {
+			event, ok := parser.parse(line)
+			if !ok {
+				return nil
+			}
+			handlerErr = handler(event)
+			return handlerErr
+		})
+
+		var httpErr *HTTPError
+		ctx := r.restyRequest.Context()
+		if !r.sseReconnect || handlerErr != nil || errors.As(err, &httpErr) || ctx.Err() != nil {
+			return err
+		}
+
+		if !sleepContext(ctx, parser.retry) {
+			return ctx.Err()
+		}
+		if parser.lastEventID != "" {
+			r.restyRequest.SetHeader("Last-Event-ID", parser.lastEventID)
+		}
+		parser.reset()
+	}
+}
+
+// sseParser parses a text/event-stream line by line.
+type sseParser struct {
+	lastEventID string
+	retry       time.Duration
+
+	event    string
+	data     strings.Builder
+	hasData  bool
+	newRetry time.Duration
+}
+
+// parse returns the event dispatched by line, if any.
+func (p *sseParser) parse(line []byte) (SSEEvent, bool) {
+	if len(line) == 0 {
+		return p.dispatch()
+	}
+	if line[0] == ':' {
+		return SSEEvent{}, false
+	}
+
+	field, value := line, []byte(nil)
+	if i := bytes.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+	}
+
+	switch string(field) {
+	case "event":
+		p.event = string(value)
+	case "data":
+		if p.hasData {
+			p.data.WriteByte('\n')
+		}
+		p.data.Write(value)
+		p.hasData = true
+	case "id":
+		if bytes.IndexByte(value, 0) < 0 {
+			p.lastEventID = string(value)
+		}
+	case "retry":
+		if ms, err := strconv.Atoi(string(value)); err == nil && ms >= 0 {
+			p.retry = time.Duration(ms) * time.Millisecond
+			p.newRetry = p.retry
+		}
+	}
+	return SSEEvent{}, false
+}
+
+func (p *sseParser) dispatch() (SSEEvent, bool) {
+	defer p.reset()
+	if !p.hasData {
+		return SSEEvent{}, false
+	}
+
+	return SSEEvent{
+		ID:    p.lastEventID,
+		Event: p.event,
+		Data:  p.data.String(),
+		Retry: p.newRetry,
+	}, true
+}
+
+// reset discards the event being parsed.
+func (p *sseParser) reset() {
+	p.event = ""
+	p.data.Reset()
+	p.hasData = false
+	p.newRetry = 0
+}
@@ -0,0 +1,69 @@
+package httpclient_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestSSE(t *testing.T) {
+	var accept, lastEventID string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		accept = req.Header.Get("Accept")
+		lastEventID = req.Header.Get("Last-Event-ID")
+		rw.Header().Set("Content-Type", "text/event-stream")
+
+		if req.URL.Path == "/reconnect" && lastEventID == "" {
+			rw.Write([]byte("id: 7\ndata: first\nretry: 10\n\n"))
+			return
+		}
+		if req.URL.Path == "/reconnect" {
+			rw.Write([]byte("data: second\n\n"))
+			return
+		}
+		rw.Write([]byte(": comment\nevent: update\ndata: a\ndata:b\nid: 1\n\ndata: c\nretry: 10\n\nid: 2\n\ndata: incomplete"))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Events", func(t *testing.T) {
+		var events []httpclient.SSEEvent
+		err := client.NewRequest().SSE("/", func(event httpclient.SSEEvent) error {
+			events = append(events, event)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "text/event-stream", accept)
+		assert.Equal(t, []httpclient.SSEEvent{
+			{ID: "1", Event: "update", Data: "a\nb"},
+			{ID: "1", Data: "c", Retry: 10 * time.Millisecond},
+		}, events)
+	})
+
+	t.Run("Reconnect", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var data []string
+		err := client.NewRequest().SetSSEReconnect(true).SSE("/reconnect", func(event httpclient.SSEEvent) error {
+			data = append(data, event.Data)
+			if event.Data == "second" {
+				return errStop
+			}
+			return nil
+		})
+
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, []string{"first", "second"}, data)
+		assert.Equal(t, "7", lastEventID)
+	})
+}
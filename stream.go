@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Stream sends the request and calls fn for each line of the response body as it
+// arrives, such as NDJSON streams, without buffering the whole body. Empty lines
+// are skipped and line endings are trimmed. The method is the one set with
+// RestyRequest().Method, GET by default.
+//
+// It stops when fn returns an error, the body ends or the request context is
+// done, and always closes the body. Non-2xx responses return a *HTTPError.
+// Timeouts such as WithTimeoutPerHost cover the whole stream, and streamed
+// requests are neither cached nor hedged.
+func (r *Request) Stream(url string, fn func(line []byte) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stream(url, func(line []byte) error {
+		if len(line) == 0 {
+			return nil
+		}
+		return fn(line)
+	})
+}
+
+// stream sends the request and calls fn for each line of the response body,
+// including empty lines.
+func (r *Request) stream(url string, fn func(line []byte) error) error {
+	method := r.restyRequest.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	r.streamLine = fn
+	r.streamErr = nil
+	r.restyRequest.SetDoNotParseResponse(true)
+	defer func() {
+		r.streamLine = nil
+		r.restyRequest.SetDoNotParseResponse(false)
+	}()
+
+	resp, err := r.execute(method, url)
+	if resp != nil && resp.rawBody != nil {
+		resp.rawBody.Close()
+	}
+	if err != nil {
+		return err
+	}
+	return r.streamErr
+}
+
+// readStream calls r.streamLine for each line of the response body.
+func (r *Request) readStream(resp *Response) error {
+	defer resp.rawBody.Close()
+
+	if resp.statusCode < 200 || resp.statusCode > 299 {
+		resp.body, _ = io.ReadAll(resp.rawBody)
+		return newHTTPError(resp)
+	}
+
+	reader := bufio.NewReader(resp.rawBody)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == nil || len(line) > 0 {
+			if err := r.streamLine(bytes.TrimRight(line, "\r\n")); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctxErr := r.restyRequest.Context().Err(); ctxErr != nil {
+				return ctxErr
+			}
+			return err
+		}
+	}
+}
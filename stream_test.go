@@ -0,0 +1,91 @@
+package httpclient_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/missing":
+			rw.WriteHeader(http.StatusNotFound)
+			rw.Write([]byte("not found"))
+			return
+		case "/endless":
+			rw.Write([]byte("{\"id\":1}\n"))
+			rw.(http.Flusher).Flush()
+			<-req.Context().Done()
+			return
+		}
+
+		for _, line := range []string{"{\"id\":1}\n", "\n", "{\"id\":2}\r\n", "{\"id\":3}"} {
+			rw.Write([]byte(line))
+			rw.(http.Flusher).Flush()
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithTimeoutResilience(time.Second),
+	)
+
+	t.Run("Lines", func(t *testing.T) {
+		var lines []string
+		err := client.NewRequest().Stream("/", func(line []byte) error {
+			lines = append(lines, string(line))
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`}, lines)
+	})
+
+	t.Run("CallbackError", func(t *testing.T) {
+		errStop := errors.New("stop")
+		calls := 0
+		err := client.NewRequest().Stream("/", func(line []byte) error {
+			calls++
+			return errStop
+		})
+
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("ContextCancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		err := client.NewRequest().SetContext(ctx).Stream("/endless", func(line []byte) error {
+			cancel()
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("HTTPError", func(t *testing.T) {
+		err := client.NewRequest().Stream("/missing", func(line []byte) error {
+			t.Error("unexpected line")
+			return nil
+		})
+
+		var httpErr *httpclient.HTTPError
+		if assert.ErrorAs(t, err, &httpErr) {
+			assert.Equal(t, http.StatusNotFound, httpErr.StatusCode)
+			assert.Equal(t, []byte("not found"), httpErr.Body)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// WithClientTrace attaches hooks to the context of every request to observe
+// connection events, such as DNS lookups, connects and TLS handshakes.
+func WithClientTrace(hooks *httptrace.ClientTrace) func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.clientTrace = hooks
+	}
+}
+
+// WithTraceMetrics pushes the duration in seconds of the connection phases of
+// every request to the metrics set with WithMetrics, in the series
+// "<alias>.dns_time", "<alias>.connect_time", "<alias>.tls_time" and "<alias>.ttfb",
+// the time waiting for the first response byte after the request is written.
+// Phases that don't happen, such as when a connection is reused, are not pushed.
+func WithTraceMetrics() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.traceMetrics = true
+	}
+}
+
+// WithConnectionReuseMetrics increments the "<alias>.conn_reused_total" counter of
+// the metrics set with WithMetrics for every attempt sent over a reused connection,
+// and the "<alias>.conn_new_total" counter for the ones that dial a new connection,
+// to diagnose connection churn. They're counted by the same transport hook as the
+// "conn_reused" series of WithTransportMetrics, and follow DisableMetrics and
+// WithSynchronousMetrics as the other request metrics do.
+func WithConnectionReuseMetrics() func(*HTTPClient) {
+	return func(client *HTTPClient) {
+		client.connMetrics = true
+		client.wrapMetricsTransport()
+	}
+}
+
+// withTrace returns ctx with the configured client traces.
+func (r *Request) withTrace(ctx context.Context) context.Context {
+	if r.clientTrace != nil {
+		ctx = httptrace.WithClientTrace(ctx, r.clientTrace)
+	}
+	if r.metrics == nil || r.metricsDisabled {
+		return ctx
+	}
+	if r.traceMetrics {
+		ctx = httptrace.WithClientTrace(ctx, r.metricsTrace())
+	}
+	return ctx
+}
+
+// metricsTrace records the phase durations of a single request.
+func (r *Request) metricsTrace() *httptrace.ClientTrace {
+	var mu sync.Mutex
+	var dnsStart, connectStart, tlsStart, wroteRequest time.Time
+
+	push := func(name string, start time.Time) {
+		if start.IsZero() {
+			return
+		}
+		r.emitSeries(name, time.Since(start).Seconds())
+	}
+
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			push("dns_time", dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			push("connect_time", connectStart)
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			push("tls_time", tlsStart)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			wroteRequest = time.Now()
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			push("ttfb", wroteRequest)
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientTrace(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	// Use a hostname to trigger a DNS lookup, and a name in the test certificate.
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.ServerName = "example.com"
+
+	var gotConn int32
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(strings.Replace(server.URL, "127.0.0.1", "localhost", 1)),
+		httpclient.WithTransport(transport),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithClientTrace(&httptrace.ClientTrace{
+			GotConn: func(httptrace.GotConnInfo) {
+				atomic.AddInt32(&gotConn, 1)
+			},
+		}),
+		httpclient.WithTraceMetrics(),
+	)
+
+	_, err := client.NewRequest().SetAlias("traced").Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&gotConn))
+	for _, name := range []string{"dns_time", "connect_time", "tls_time", "ttfb"} {
+		assert.Eventually(t, func() bool { return metrics.seriesLen("traced."+name) == 1 }, time.Second, 10*time.Millisecond, name)
+	}
+}
+
+func TestConnectionReuseMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithConnectionReuseMetrics(),
+	)
+
+	for i := 0; i < 3; i++ {
+		_, err := client.NewRequest().SetAlias("conn").Get("/")
+		assert.NoError(t, err)
+	}
+	_, err := client.NewRequest().SetAlias("disabled").DisableMetrics().Get("/")
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		return metrics.counter("conn.conn_new_total") == 1 && metrics.counter("conn.conn_reused_total") == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Zero(t, metrics.counter("disabled.conn_reused_total"))
+
+	t.Run("TransportMetrics", func(t *testing.T) {
+		metrics := newMetricsRecorder()
+		client := httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithMetrics(metrics),
+			httpclient.WithSynchronousMetrics(),
+			httpclient.WithConnectionReuseMetrics(),
+			httpclient.WithTransportMetrics(),
+		)
+
+		for i := 0; i < 2; i++ {
+			_, err := client.NewRequest().SetAlias("conn").Get("/")
+			assert.NoError(t, err)
+		}
+
+		assert.Equal(t, 1, metrics.counter("conn.conn_new_total"))
+		assert.Equal(t, 1, metrics.counter("conn.conn_reused_total"))
+		assert.Equal(t, []float64{0, 1}, metrics.values("conn.conn_reused"))
+	})
+}
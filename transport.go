@@ -2,19 +2,57 @@ package httpclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
+// ErrResponseTooLarge is returned when a response body exceeds the size set by WithMaxResponseBodySize.
+var ErrResponseTooLarge = errors.New("response body too large")
+
 // Transport accepts a custom RoundTripper and acts as a middleware to facilitate logging and
 // argument passing to external requests.
 type Transport struct {
 	RoundTripper http.RoundTripper
+
+	// headers are the context values propagated as headers, defaultContextHeaders
+	// when nil.
+	headers []contextHeader
+	// metrics records the metrics of each request, when not nil: the ones of
+	// WithTransportMetrics when sizeMetrics is set, and the connection counters of
+	// WithConnectionReuseMetrics when connMetrics is set, all of them emitted
+	// inline when syncMetrics is set.
+	metrics     Metrics
+	sizeMetrics bool
+	connMetrics bool
+	syncMetrics bool
+}
+
+// contextHeader maps a context key to the request header its value is sent as.
+type contextHeader struct {
+	key    interface{}
+	header string
 }
 
+// defaultContextHeaders propagates the request ID.
+var defaultContextHeaders = []contextHeader{{key: contextRequestIDKey, header: "X-Request-ID"}}
+
 // RoundTrip acts as a middleware performing external requests logging and argument passing to
 // external requests.
 func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
-	t.setRequestIDHeader(req.Context(), req)
+	req = t.setContextHeaders(req.Context(), req)
+	if t.metrics != nil {
+		if key, ok := metricsKeyFromContext(req.Context()); !ok || key != "" {
+			return t.roundTripWithMetrics(req, key)
+		}
+	}
+
 	resp, err := t.RoundTripper.RoundTrip(req)
 	if err != nil {
 		return nil, err
@@ -23,10 +61,204 @@ func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	return resp, err
 }
 
-func (t *Transport) setRequestIDHeader(ctx context.Context, req *http.Request) {
-	rID := requestID(ctx)
-	if rID == "" {
+// roundTripWithMetrics sends req recording, under its metrics key, the request and
+// response body sizes, once the bodies are closed, and whether the connection was
+// reused.
+func (t *Transport) roundTripWithMetrics(req *http.Request, key string) (*http.Response, error) {
+	if key == "" {
+		key = strings.Replace(req.URL.Host, ".", "-", -1)
+	}
+
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if t.sizeMetrics {
+				reused := 0.0
+				if info.Reused {
+					reused = 1
+				}
+				t.emit(func(metrics Metrics) {
+					metrics.PushToSeries(fmt.Sprintf("%s.%s", key, "conn_reused"), reused)
+				})
+			}
+			if t.connMetrics {
+				name := fmt.Sprintf("%s.%s", key, "conn_new_total")
+				if info.Reused {
+					name = fmt.Sprintf("%s.%s", key, "conn_reused_total")
+				}
+				t.emit(func(metrics Metrics) {
+					metrics.IncrCounter(name)
+				})
+			}
+		},
+	})
+	req = req.Clone(ctx)
+	if !t.sizeMetrics {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = newCountingBody(req.Body, func(n int64) {
+			t.emit(func(metrics Metrics) {
+				metrics.PushToSeries(fmt.Sprintf("%s.%s", key, "request_bytes"), float64(n))
+			})
+		})
+	}
+
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Body = newCountingBody(resp.Body, func(n int64) {
+		t.emit(func(metrics Metrics) {
+			metrics.PushToSeries(fmt.Sprintf("%s.%s", key, "response_bytes"), float64(n))
+		})
+	})
+	return resp, nil
+}
+
+// emit records metrics with emit, inline when syncMetrics is set, and in a
+// separate goroutine otherwise, as the metrics of the requests are.
+func (t *Transport) emit(emit func(metrics Metrics)) {
+	if t.syncMetrics {
+		emit(t.metrics)
 		return
 	}
-	req.Header.Add("X-Request-ID", rID)
+	go emit(t.metrics)
+}
+
+// countingBody counts the bytes read from the body, reporting them on Close.
+type countingBody struct {
+	io.ReadCloser
+	count  int64
+	report func(int64)
+	once   sync.Once
+}
+
+func newCountingBody(body io.ReadCloser, report func(int64)) *countingBody {
+	return &countingBody{ReadCloser: body, report: report}
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.count += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.once.Do(func() {
+		b.report(b.count)
+	})
+	return b.ReadCloser.Close()
+}
+
+// setContextHeaders returns req with the headers of the context values present on
+// ctx. Values the request already has are skipped, as when a wrapping Transport
+// propagated them. The request is copied before setting any header, so it isn't
+// modified.
+func (t *Transport) setContextHeaders(ctx context.Context, req *http.Request) *http.Request {
+	headers := t.headers
+	if headers == nil {
+		headers = defaultContextHeaders
+	}
+
+	cloned := false
+	for _, h := range headers {
+		value := contextHeaderValue(ctx, h.key)
+		if value == "" || hasHeaderValue(req.Header, h.header, value) {
+			continue
+		}
+
+		if !cloned {
+			req = req.Clone(ctx)
+			cloned = true
+		}
+		req.Header.Add(h.header, value)
+	}
+	return req
+}
+
+// hasHeaderValue reports whether value is one of the values of the name header.
+func hasHeaderValue(header http.Header, name, value string) bool {
+	for _, v := range header.Values(name) {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// contextHeaderValue returns the value of key on ctx as a header value, with
+// strings kept as is and other values formatted with fmt.
+func contextHeaderValue(ctx context.Context, key interface{}) string {
+	switch value := ctx.Value(key).(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// deadlineTransport sets the time remaining until the request context deadline, in
+// milliseconds, on header.
+type deadlineTransport struct {
+	http.RoundTripper
+	header string
+}
+
+func (t *deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	deadline, ok := req.Context().Deadline()
+	if !ok {
+		return t.RoundTripper.RoundTrip(req)
+	}
+
+	remaining := time.Until(deadline).Milliseconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set(t.header, strconv.FormatInt(remaining, 10))
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// bodyLimitTransport fails responses whose body is larger than maxBytes.
+type bodyLimitTransport struct {
+	http.RoundTripper
+	maxBytes int64
+}
+
+func (t *bodyLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.ContentLength > t.maxBytes {
+		resp.Body.Close()
+		return nil, ErrResponseTooLarge
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, remaining: t.maxBytes}
+	return resp, nil
+}
+
+// limitedBody returns ErrResponseTooLarge once more than remaining bytes are read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, ErrResponseTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
 }
@@ -0,0 +1,196 @@
+package httpclient_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/globocom/httpclient"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxResponseBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := strings.Repeat("a", 100)
+		if req.URL.Path == "/chunked" {
+			rw.Write([]byte(body[:50]))
+			rw.(http.Flusher).Flush()
+			rw.Write([]byte(body[50:]))
+			return
+		}
+		rw.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	newClient := func(maxBytes int64) *httpclient.HTTPClient {
+		return httpclient.NewHTTPClient(
+			&httpclient.LoggerAdapter{Writer: io.Discard},
+			httpclient.WithHostURL(server.URL),
+			httpclient.WithMaxResponseBodySize(maxBytes),
+		)
+	}
+
+	t.Run("WithinLimit", func(t *testing.T) {
+		resp, err := newClient(100).NewRequest().Get("/chunked")
+		assert.NoError(t, err)
+		assert.Len(t, resp.Body(), 100)
+	})
+
+	t.Run("ContentLengthExceeded", func(t *testing.T) {
+		_, err := newClient(99).NewRequest().Get("/")
+		assert.ErrorIs(t, err, httpclient.ErrResponseTooLarge)
+	})
+
+	t.Run("BodyExceeded", func(t *testing.T) {
+		_, err := newClient(60).NewRequest().Get("/chunked")
+		assert.ErrorIs(t, err, httpclient.ErrResponseTooLarge)
+	})
+}
+
+type tenantKey struct{}
+
+func TestContextHeaders(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDefaultTransport(time.Second),
+		httpclient.WithContextHeaders(map[interface{}]string{
+			tenantKey{}:  "X-Tenant-ID",
+			"locale":     "Accept-Language",
+			"trace":      "X-Trace",
+			"request.id": "X-Request-ID",
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	ctx = context.WithValue(ctx, "trace", true)
+	ctx = context.WithValue(ctx, "request.id", "42")
+	_, err := client.NewRequest().SetContext(ctx).Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", headers.Get("X-Tenant-ID"))
+	assert.Equal(t, "true", headers.Get("X-Trace"))
+	assert.Equal(t, []string{"42"}, headers.Values("X-Request-ID"))
+	assert.NotContains(t, headers, "Accept-Language")
+}
+
+func TestTransportRequestID(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDefaultTransport(time.Second),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+	assert.NotContains(t, headers, "X-Request-Id")
+
+	ctx := context.WithValue(context.Background(), "request.id", "42")
+	_, err = client.NewRequest().SetContext(ctx).Get("/")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", headers.Get("X-Request-ID"))
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportWrapper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(handleFunc))
+	defer server.Close()
+
+	var calls []string
+	wrapper := func(name string) func(http.RoundTripper) http.RoundTripper {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name)
+				return next.RoundTrip(req)
+			})
+		}
+	}
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithTransportWrapper(wrapper("inner")),
+		httpclient.WithTransportWrapper(wrapper("outer")),
+		httpclient.WithResponseHeaderTimeout(time.Second),
+	)
+
+	_, err := client.NewRequest().Get("/")
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer", "inner"}, calls)
+}
+
+func TestTransportMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		io.Copy(io.Discard, req.Body)
+		rw.Write([]byte("world!"))
+	}))
+	defer server.Close()
+
+	metrics := newMetricsRecorder()
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithMetrics(metrics),
+		httpclient.WithTransportMetrics(),
+		httpclient.WithSynchronousMetrics(),
+	)
+
+	for i := 0; i < 2; i++ {
+		_, err := client.NewRequest().SetAlias("echo").SetBody("hello").Post("/")
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []float64{5, 5}, metrics.values("echo.request_bytes"))
+	assert.Equal(t, []float64{6, 6}, metrics.values("echo.response_bytes"))
+	assert.Equal(t, []float64{0, 1}, metrics.values("echo.conn_reused"))
+}
+
+func TestDeadlinePropagation(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		headers = req.Header
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithDeadlinePropagation("X-Request-Timeout"),
+	)
+
+	_, err := client.NewRequest().Get("/")
+	assert.NoError(t, err)
+	assert.NotContains(t, headers, "X-Request-Timeout")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.NewRequest().SetContext(ctx).Get("/")
+	assert.NoError(t, err)
+
+	remaining, err := strconv.Atoi(headers.Get("X-Request-Timeout"))
+	assert.NoError(t, err)
+	assert.InDelta(t, 5000, remaining, 1000)
+}
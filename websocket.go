@@ -0,0 +1,157 @@
+package httpclient
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// UpgradeWebSocket opens a WebSocket connection to url, which can use the http(s)
+// or ws(s) schemes. The handshake runs the request middlewares and sends the
+// client and request headers, query params, authentication and cookies, including
+// the ones of the cookie jar, signed by the request signers, through the dialer,
+// proxy and TLS settings of the client transport. It's registered in the metrics
+// as a GET request.
+//
+// The handshake is sent once: the circuit breaker, retries, backoff and hedging
+// are not applied to it. Neither are the transport wrappers, such as the OAuth and
+// NTLM transports, the response middlewares, cache, request timeouts and logger. The
+// signers sign the handshake request without its WebSocket headers.
+func (r *Request) UpgradeWebSocket(url string) (*websocket.Conn, *Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.method = http.MethodGet
+	r.url = url
+	r.attempts = 1
+	r.pickHost()
+	r.metricsAlias = r.metricsKey(http.MethodGet, url)
+
+	var conn *websocket.Conn
+	resp, err := r.registerMetrics(r.restyRequest.Context(), func() (*Response, error) {
+		for _, middleware := range r.requestMiddlewares {
+			if err := middleware(r); err != nil {
+				return nil, err
+			}
+		}
+
+		url := r.webSocketURL(url)
+		req, err := r.webSocketRequest(url)
+		if err != nil {
+			return nil, err
+		}
+
+		r.startTime = r.clock()
+		var handshake *http.Response
+		conn, handshake, err = r.webSocketDialer().DialContext(req.Context(), url, req.Header)
+		if handshake == nil {
+			return nil, err
+		}
+		if jar := r.restyClient.GetClient().Jar; jar != nil {
+			jar.SetCookies(req.URL, handshake.Cookies())
+		}
+		return r.wrapHandshake(handshake), err
+	})
+	return conn, resp, err
+}
+
+func (r *Request) webSocketDialer() *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: r.restyClient.GetClient().Timeout,
+	}
+	if transport := baseTransport(r.restyClient.GetClient().Transport); transport != nil {
+		dialer.NetDialContext = transport.DialContext
+		dialer.Proxy = transport.Proxy
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	return dialer
+}
+
+func (r *Request) webSocketURL(url string) string {
+	url = r.withRawQuery(r.resolveURL(url))
+	if strings.HasPrefix(url, "http") {
+		url = "ws" + strings.TrimPrefix(url, "http")
+	}
+	if query := r.restyRequest.QueryParam.Encode(); query != "" {
+		if strings.Contains(url, "?") {
+			url += "&" + query
+		} else {
+			url += "?" + query
+		}
+	}
+	return url
+}
+
+// webSocketRequest builds the handshake request to url, without the WebSocket
+// headers, so it's signed by the signers. It uses the http(s) scheme, as the
+// cookie jar and signers expect.
+func (r *Request) webSocketRequest(url string) (*http.Request, error) {
+	if strings.HasPrefix(url, "ws") {
+		url = "http" + strings.TrimPrefix(url, "ws")
+	}
+	req, err := http.NewRequestWithContext(r.restyRequest.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header = r.restyClient.Header.Clone()
+	for name, values := range r.restyRequest.Header {
+		req.Header[name] = values
+	}
+
+	token, scheme := r.restyRequest.Token, r.restyRequest.AuthScheme
+	if token == "" {
+		token, scheme = r.restyClient.Token, r.restyClient.AuthScheme
+	}
+	userInfo := r.restyRequest.UserInfo
+	if userInfo == nil {
+		userInfo = r.restyClient.UserInfo
+	}
+
+	switch {
+	case token != "":
+		if scheme == "" {
+			scheme = "Bearer"
+		}
+		req.Header.Set("Authorization", scheme+" "+token)
+	case userInfo != nil:
+		credentials := base64.StdEncoding.EncodeToString([]byte(userInfo.Username + ":" + userInfo.Password))
+		req.Header.Set("Authorization", "Basic "+credentials)
+	}
+
+	cookies := append(append([]*http.Cookie(nil), r.restyClient.Cookies...), r.restyRequest.Cookies...)
+	if jar := r.restyClient.GetClient().Jar; jar != nil {
+		cookies = append(cookies, jar.Cookies(req.URL)...)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	for _, signer := range r.signers {
+		if err := signer.Sign(req, nil); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (r *Request) wrapHandshake(handshake *http.Response) *Response {
+	body, _ := io.ReadAll(handshake.Body)
+	receivedAt := r.clock()
+	return &Response{
+		attempts:     r.attempts,
+		statusCode:   handshake.StatusCode,
+		status:       handshake.Status,
+		body:         body,
+		header:       handshake.Header,
+		cookies:      handshake.Cookies(),
+		request:      r,
+		responseTime: receivedAt.Sub(r.startTime),
+		sentAt:       r.startTime,
+		receivedAt:   receivedAt,
+	}
+}
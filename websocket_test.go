@@ -0,0 +1,115 @@
+package httpclient_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/globocom/httpclient"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestUpgradeWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" || req.URL.Query().Get("room") != "1" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(rw, req, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		messageType, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		conn.WriteMessage(messageType, append([]byte("echo: "), message...))
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+	)
+
+	t.Run("Upgrade", func(t *testing.T) {
+		conn, resp, err := client.NewRequest().
+			SetAuthToken("token").
+			SetQueryParams(map[string]string{"room": "1"}).
+			UpgradeWebSocket("/ws")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode())
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("hello")))
+		_, message, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		assert.Equal(t, "echo: hello", string(message))
+	})
+
+	t.Run("HandshakeError", func(t *testing.T) {
+		conn, resp, err := client.NewRequest().UpgradeWebSocket("/ws")
+
+		assert.ErrorIs(t, err, websocket.ErrBadHandshake)
+		assert.Nil(t, conn)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode())
+	})
+}
+
+func TestRequestUpgradeWebSocketCookiesAndSigners(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/login" {
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "42"})
+			return
+		}
+		session, err := req.Cookie("session")
+		if err != nil || session.Value != "42" || req.Header.Get("X-Signature") != "GET /ws?room=1" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		header := http.Header{}
+		header.Add("Set-Cookie", (&http.Cookie{Name: "room", Value: "1"}).String())
+		conn, err := upgrader.Upgrade(rw, req, header)
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}))
+	defer server.Close()
+
+	client := httpclient.NewHTTPClient(
+		&httpclient.LoggerAdapter{Writer: io.Discard},
+		httpclient.WithHostURL(server.URL),
+		httpclient.WithInMemoryCookieJar(),
+		httpclient.WithRequestSigner(httpclient.RequestSignerFunc(func(req *http.Request, body []byte) error {
+			req.Header.Set("X-Signature", req.Method+" "+req.URL.RequestURI())
+			return nil
+		})),
+	)
+
+	_, err := client.NewRequest().Post("/login")
+	assert.NoError(t, err)
+
+	conn, resp, err := client.NewRequest().
+		SetQueryParams(map[string]string{"room": "1"}).
+		UpgradeWebSocket("/ws")
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn.Close()
+
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode())
+	serverURL, _ := url.Parse(server.URL)
+	assert.Len(t, client.GetClient().Jar.Cookies(serverURL), 2)
+}